@@ -0,0 +1,36 @@
+package supportconfig
+
+import "io"
+
+// Analyzer adds to a parser the handlers of one of the structured
+// parsers, such as SELinuxAnalyzer, and returns a function to be called
+// once the parsing ends successfully, which completes its result.
+//
+// Analyzers let callers pick the structured parsers they need and run
+// them in a single pass over the source, either with Analyze or along
+// with their own handlers on a parser of theirs.
+type Analyzer func(p *Parser) (done func() error)
+
+// Analyze parses the source once, feeding all the given analyzers
+func Analyze(source io.Reader, analyzers ...Analyzer) error {
+	p := NewParser()
+	dones := make([]func() error, 0, len(analyzers))
+	for _, analyzer := range analyzers {
+		dones = append(dones, analyzer(p))
+	}
+	if err := p.Parse(source); err != nil {
+		return err
+	}
+	for _, done := range dones {
+		if err := done(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noDone is the done function of the analyzers with nothing left to do
+// when the parsing ends
+func noDone() error {
+	return nil
+}
@@ -0,0 +1,59 @@
+package supportconfig_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type analyzeSuite struct {
+}
+
+var _ = Suite(&analyzeSuite{})
+
+func (as *analyzeSuite) TestAnalyze(c *C) {
+	source := sampleSELinux + sampleWicked + sampleSS + sampleConntrack
+	var status supportconfig.SELinuxStatus
+	var network supportconfig.NetworkConfig
+	var sockets supportconfig.SocketSummary
+	var conntrack supportconfig.Conntrack
+	tree := supportconfig.NewTree()
+	err := supportconfig.Analyze(strings.NewReader(source),
+		supportconfig.SELinuxAnalyzer(&status),
+		supportconfig.NetworkAnalyzer(&network),
+		supportconfig.SocketsAnalyzer(&sockets),
+		supportconfig.ConntrackAnalyzer(&conntrack),
+		supportconfig.TreeAnalyzer(tree))
+	c.Assert(err, IsNil)
+
+	expectedStatus, err := supportconfig.ParseSELinux(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	c.Assert(status, DeepEquals, *expectedStatus)
+	expectedNetwork, err := supportconfig.ParseNetwork(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	c.Assert(network, DeepEquals, *expectedNetwork)
+	expectedSockets, err := supportconfig.ParseSockets(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	c.Assert(sockets, DeepEquals, *expectedSockets)
+	c.Assert(conntrack.Drops, Equals, 2)
+	value, found := tree.Get("/proc/sys/net/netfilter/nf_conntrack_max")
+	c.Assert(found, Equals, true)
+	c.Assert(value, Equals, "65536")
+}
+
+func (as *analyzeSuite) TestAnalyzeDoneError(c *C) {
+	var calls []string
+	failing := func(p *supportconfig.Parser) func() error {
+		p.OnSectionStart(func(section, header string) {
+			calls = append(calls, header)
+		})
+		return func() error {
+			return errors.New("incomplete")
+		}
+	}
+	err := supportconfig.Analyze(strings.NewReader(sampleMultipleGroups), failing)
+	c.Assert(err, ErrorMatches, "incomplete")
+	c.Assert(calls, HasLen, 4)
+}
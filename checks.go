@@ -0,0 +1,450 @@
+package supportconfig
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Finding is a setting found not to follow the expectations of a check
+type Finding struct {
+	// Path is where the setting was read from
+	Path string
+
+	// Value is the setting as found
+	Value string
+
+	// Message describes what was expected
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s (found %q)", f.Path, f.Message, f.Value)
+}
+
+// IOExpectations has the block device queue settings expected for a kind
+// of workload, such as a database or a virtualization host. Zero values
+// are not checked.
+type IOExpectations struct {
+	// Schedulers are the I/O schedulers accepted as the active one
+	Schedulers []string
+
+	// MinNrRequests is the minimum size of the request queues
+	MinNrRequests int64
+
+	// MinReadAheadKB and MaxReadAheadKB bound the read ahead
+	MinReadAheadKB int64
+	MaxReadAheadKB int64
+}
+
+// selectedChoice returns the choice selected in the contents of a sysfs
+// file listing the available ones, such as "noop deadline [cfq]"
+func selectedChoice(value string) string {
+	fields := strings.Fields(value)
+	for _, field := range fields {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			return field[1 : len(field)-1]
+		}
+	}
+	if len(fields) == 1 {
+		return fields[0]
+	}
+	return ""
+}
+
+// CheckIO checks the queue settings of the block devices found in the
+// tree against the expectations
+func CheckIO(tree *Tree, expected IOExpectations) []Finding {
+	var findings []Finding
+
+	devices := tree.Children("/sys/block")
+	for _, device := range devices {
+		queue := path.Join("/sys/block", device, "queue")
+
+		if len(expected.Schedulers) > 0 {
+			name := path.Join(queue, "scheduler")
+			if value, found := tree.Get(name); found {
+				active := selectedChoice(value)
+				if !containsString(expected.Schedulers, active) {
+					findings = append(findings, Finding{Path: name, Value: value,
+						Message: fmt.Sprintf("scheduler should be one of %s", strings.Join(expected.Schedulers, ", "))})
+				}
+			}
+		}
+		if expected.MinNrRequests > 0 {
+			name := path.Join(queue, "nr_requests")
+			if value, found := tree.Get(name); found {
+				if n, err := strconv.ParseInt(value, 10, 64); err != nil || n < expected.MinNrRequests {
+					findings = append(findings, Finding{Path: name, Value: value,
+						Message: fmt.Sprintf("nr_requests should be at least %d", expected.MinNrRequests)})
+				}
+			}
+		}
+		if expected.MinReadAheadKB > 0 || expected.MaxReadAheadKB > 0 {
+			name := path.Join(queue, "read_ahead_kb")
+			if value, found := tree.Get(name); found {
+				n, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || n < expected.MinReadAheadKB ||
+					(expected.MaxReadAheadKB > 0 && n > expected.MaxReadAheadKB) {
+					findings = append(findings, Finding{Path: name, Value: value,
+						Message: readAheadMessage(expected)})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+func readAheadMessage(expected IOExpectations) string {
+	switch {
+	case expected.MaxReadAheadKB == 0:
+		return fmt.Sprintf("read_ahead_kb should be at least %d", expected.MinReadAheadKB)
+	case expected.MinReadAheadKB == 0:
+		return fmt.Sprintf("read_ahead_kb should be at most %d", expected.MaxReadAheadKB)
+	}
+	return fmt.Sprintf("read_ahead_kb should be between %d and %d", expected.MinReadAheadKB, expected.MaxReadAheadKB)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Range bounds a numeric setting, both ends included
+type Range struct {
+	Min, Max int64
+}
+
+func (r Range) String() string {
+	if r.Min == r.Max {
+		return strconv.FormatInt(r.Min, 10)
+	}
+	return fmt.Sprintf("between %d and %d", r.Min, r.Max)
+}
+
+// MemoryExpectations has the virtual memory settings recommended for a
+// product, such as SAP HANA or a database. Nil values are not checked.
+type MemoryExpectations struct {
+	// THP are the transparent hugepage modes accepted
+	THP []string
+
+	// Swappiness, DirtyRatio and DirtyBackgroundRatio bound the sysctls
+	// with the same names
+	Swappiness           *Range
+	DirtyRatio           *Range
+	DirtyBackgroundRatio *Range
+}
+
+// thpEnabled is where the transparent hugepage mode is found
+const thpEnabled = "/sys/kernel/mm/transparent_hugepage/enabled"
+
+// CheckMemory checks the transparent hugepage and virtual memory
+// settings found in the tree against the expectations
+func CheckMemory(tree *Tree, expected MemoryExpectations) []Finding {
+	var findings []Finding
+
+	if len(expected.THP) > 0 {
+		if value, found := tree.Get(thpEnabled); found {
+			if !containsString(expected.THP, selectedChoice(value)) {
+				findings = append(findings, Finding{Path: thpEnabled, Value: value,
+					Message: fmt.Sprintf("transparent hugepages should be %s", strings.Join(expected.THP, " or "))})
+			}
+		}
+	}
+	for _, sysctl := range []struct {
+		name     string
+		expected *Range
+	}{
+		{"swappiness", expected.Swappiness},
+		{"dirty_ratio", expected.DirtyRatio},
+		{"dirty_background_ratio", expected.DirtyBackgroundRatio},
+	} {
+		if sysctl.expected == nil {
+			continue
+		}
+		name := path.Join("/proc/sys/vm", sysctl.name)
+		value, found := tree.Get(name)
+		if !found {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < sysctl.expected.Min || n > sysctl.expected.Max {
+			findings = append(findings, Finding{Path: name, Value: value,
+				Message: fmt.Sprintf("%s should be %s", sysctl.name, sysctl.expected)})
+		}
+	}
+	return findings
+}
+
+// vulnerabilitiesDir is where the kernel reports the CPU vulnerabilities
+// and their mitigations
+const vulnerabilitiesDir = "/sys/devices/system/cpu/vulnerabilities"
+
+// vulnerabilityCVEs has the CVEs of the vulnerabilities reported by the
+// kernel
+var vulnerabilityCVEs = map[string][]string{
+	"meltdown":             {"CVE-2017-5754"},
+	"spectre_v1":           {"CVE-2017-5753"},
+	"spectre_v2":           {"CVE-2017-5715"},
+	"spec_store_bypass":    {"CVE-2018-3639"},
+	"l1tf":                 {"CVE-2018-3615", "CVE-2018-3620", "CVE-2018-3646"},
+	"mds":                  {"CVE-2018-12126", "CVE-2018-12127", "CVE-2018-12130", "CVE-2019-11091"},
+	"tsx_async_abort":      {"CVE-2019-11135"},
+	"itlb_multihit":        {"CVE-2018-12207"},
+	"srbds":                {"CVE-2020-0543"},
+	"mmio_stale_data":      {"CVE-2022-21123", "CVE-2022-21125", "CVE-2022-21166"},
+	"retbleed":             {"CVE-2022-29900", "CVE-2022-29901"},
+	"gather_data_sampling": {"CVE-2022-40982"},
+	"spec_rstack_overflow": {"CVE-2023-20569"},
+}
+
+// Vulnerability is the status of a CPU vulnerability as reported by the
+// kernel
+type Vulnerability struct {
+	// Name is the name used by the kernel, such as spectre_v2
+	Name string
+
+	// CVEs are the identifiers of the vulnerability, when known
+	CVEs []string
+
+	// Status is the status reported, such as "Mitigation: PTI"
+	Status string
+}
+
+// Vulnerable says whether the system is left exposed
+func (v Vulnerability) Vulnerable() bool {
+	return strings.HasPrefix(v.Status, "Vulnerable")
+}
+
+// CPUVulnerabilities returns the status of the CPU vulnerabilities found
+// in the tree, sorted by name
+func CPUVulnerabilities(tree *Tree) []Vulnerability {
+	var vulnerabilities []Vulnerability
+	for _, name := range tree.Children(vulnerabilitiesDir) {
+		status, found := tree.Get(path.Join(vulnerabilitiesDir, name))
+		if !found {
+			continue
+		}
+		vulnerabilities = append(vulnerabilities, Vulnerability{Name: name, CVEs: vulnerabilityCVEs[name], Status: status})
+	}
+	return vulnerabilities
+}
+
+// CheckCPUVulnerabilities reports the CPU vulnerabilities left without
+// mitigation, and the mitigations disabled in the kernel command line
+func CheckCPUVulnerabilities(tree *Tree) []Finding {
+	var findings []Finding
+	if cmdline, found := tree.Get("/proc/cmdline"); found {
+		for _, param := range strings.Fields(cmdline) {
+			if param == "mitigations=off" {
+				findings = append(findings, Finding{Path: "/proc/cmdline", Value: cmdline,
+					Message: "CPU vulnerability mitigations should not be disabled"})
+			}
+		}
+	}
+	for _, v := range CPUVulnerabilities(tree) {
+		if !v.Vulnerable() {
+			continue
+		}
+		message := v.Name + " should be mitigated"
+		if len(v.CVEs) > 0 {
+			message += " (" + strings.Join(v.CVEs, ", ") + ")"
+		}
+		findings = append(findings, Finding{Path: path.Join(vulnerabilitiesDir, v.Name), Value: v.Status, Message: message})
+	}
+	return findings
+}
+
+// CheckFIPS reports the settings that keep the system from running in
+// FIPS 140 mode: the kernel FIPS mode, the fips=1 boot parameter and the
+// system wide crypto policy, when any of them is found in the tree
+func CheckFIPS(tree *Tree) []Finding {
+	var findings []Finding
+	const enabled = "/proc/sys/crypto/fips_enabled"
+	if value, found := tree.Get(enabled); found && value != "1" {
+		findings = append(findings, Finding{Path: enabled, Value: value,
+			Message: "the kernel should run in FIPS mode"})
+	}
+	if cmdline, found := tree.Get("/proc/cmdline"); found {
+		if !containsString(strings.Fields(cmdline), "fips=1") {
+			findings = append(findings, Finding{Path: "/proc/cmdline", Value: cmdline,
+				Message: "the kernel should be booted with fips=1"})
+		}
+	}
+	const policy = "/etc/crypto-policies/config"
+	if content, found := tree.Get(policy); found {
+		value := configValue(content)
+		if value != "FIPS" && !strings.HasPrefix(value, "FIPS:") {
+			findings = append(findings, Finding{Path: policy, Value: value,
+				Message: "the crypto policy should be FIPS"})
+		}
+	}
+	return findings
+}
+
+// AccountAging has the password aging of an account, from the shadow
+// file. Fields not set are -1.
+type AccountAging struct {
+	User string
+
+	// LastChange and Expire are days since the epoch
+	LastChange int64
+	Expire     int64
+
+	MinDays      int64
+	MaxDays      int64
+	WarnDays     int64
+	InactiveDays int64
+
+	// Locked says whether the password is locked, NoPassword whether
+	// the account can't be logged in with a password at all and
+	// EmptyPassword whether it can be logged in without one
+	Locked        bool
+	NoPassword    bool
+	EmptyPassword bool
+}
+
+// PasswordPolicy has the password settings found in a supportconfig,
+// for compliance reviews. Password hashes are never part of it.
+type PasswordPolicy struct {
+	// MaxDays, MinDays, WarnAge and MinLength are the PASS_ settings of
+	// login.defs, -1 when not set
+	MaxDays   int64
+	MinDays   int64
+	WarnAge   int64
+	MinLength int64
+
+	// EncryptMethod is the ENCRYPT_METHOD of login.defs
+	EncryptMethod string
+
+	// Quality has the pwquality settings, from pwquality.conf and the
+	// arguments of the pam_pwquality or pam_cracklib modules, which
+	// take precedence
+	Quality map[string]string
+
+	// Accounts has the password aging of every account
+	Accounts []AccountAging
+}
+
+// configLines returns the lines of a configuration file that are neither
+// empty nor comments
+func configLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// configValue returns the first line of a configuration file that is
+// neither empty nor a comment
+func configValue(content string) string {
+	if lines := configLines(content); len(lines) > 0 {
+		return lines[0]
+	}
+	return ""
+}
+
+// pamFields splits a line of a PAM configuration in its fields, keeping
+// a control in brackets, such as [success=1 default=ignore], in one
+func pamFields(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "[") {
+		return fields
+	}
+	for i := 1; i < len(fields); i++ {
+		if strings.HasSuffix(fields[i], "]") {
+			control := strings.Join(fields[1:i+1], " ")
+			return append([]string{fields[0], control}, fields[i+1:]...)
+		}
+	}
+	return fields
+}
+
+func parseDays(value string) int64 {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// ReadPasswordPolicy builds the password policy report from the
+// login.defs, pwquality, PAM and shadow files found in the tree
+func ReadPasswordPolicy(tree *Tree) PasswordPolicy {
+	policy := PasswordPolicy{MaxDays: -1, MinDays: -1, WarnAge: -1, MinLength: -1, Quality: make(map[string]string)}
+
+	if content, found := tree.Get("/etc/login.defs"); found {
+		for _, line := range configLines(content) {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[0] {
+			case "PASS_MAX_DAYS":
+				policy.MaxDays = parseDays(fields[1])
+			case "PASS_MIN_DAYS":
+				policy.MinDays = parseDays(fields[1])
+			case "PASS_WARN_AGE":
+				policy.WarnAge = parseDays(fields[1])
+			case "PASS_MIN_LEN":
+				policy.MinLength = parseDays(fields[1])
+			case "ENCRYPT_METHOD":
+				policy.EncryptMethod = fields[1]
+			}
+		}
+	}
+	if content, found := tree.Get("/etc/security/pwquality.conf"); found {
+		for _, line := range configLines(content) {
+			if idx := strings.Index(line, "="); idx > 0 {
+				policy.Quality[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+			}
+		}
+	}
+	if content, found := tree.Get("/etc/pam.d/common-password"); found {
+		for _, line := range configLines(content) {
+			fields := pamFields(line)
+			if len(fields) < 3 || fields[0] != "password" {
+				continue
+			}
+			module := path.Base(fields[2])
+			if module != "pam_pwquality.so" && module != "pam_cracklib.so" {
+				continue
+			}
+			for _, arg := range fields[3:] {
+				if idx := strings.Index(arg, "="); idx > 0 {
+					policy.Quality[arg[:idx]] = arg[idx+1:]
+				}
+			}
+		}
+	}
+	if content, found := tree.Get("/etc/shadow"); found {
+		for _, line := range configLines(content) {
+			fields := strings.Split(line, ":")
+			if len(fields) < 8 {
+				continue
+			}
+			policy.Accounts = append(policy.Accounts, AccountAging{
+				User:          fields[0],
+				LastChange:    parseDays(fields[2]),
+				MinDays:       parseDays(fields[3]),
+				MaxDays:       parseDays(fields[4]),
+				WarnDays:      parseDays(fields[5]),
+				InactiveDays:  parseDays(fields[6]),
+				Expire:        parseDays(fields[7]),
+				Locked:        strings.HasPrefix(fields[1], "!"),
+				NoPassword:    fields[1] == "*" || fields[1] == "!" || fields[1] == "!*",
+				EmptyPassword: fields[1] == "",
+			})
+		}
+	}
+	return policy
+}
@@ -0,0 +1,191 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type checksSuite struct {
+}
+
+var _ = Suite(&checksSuite{})
+
+func sampleTree(values map[string]string) *supportconfig.Tree {
+	tree := supportconfig.NewTree()
+	for name, value := range values {
+		tree.Set(name, value)
+	}
+	return tree
+}
+
+func (cs *checksSuite) TestCheckIO(c *C) {
+	tree := sampleTree(map[string]string{
+		"/sys/block/sda/queue/scheduler":     "noop deadline [cfq]",
+		"/sys/block/sda/queue/nr_requests":   "128",
+		"/sys/block/sda/queue/read_ahead_kb": "128",
+		"/sys/block/sdb/queue/scheduler":     "[mq-deadline] none",
+		"/sys/block/sdb/queue/nr_requests":   "256",
+		"/sys/block/sdb/queue/read_ahead_kb": "4096",
+		"/sys/block/vda/queue/scheduler":     "none",
+	})
+
+	database := supportconfig.IOExpectations{
+		Schedulers:     []string{"deadline", "mq-deadline", "noop", "none"},
+		MinNrRequests:  256,
+		MaxReadAheadKB: 1024,
+	}
+	c.Assert(supportconfig.CheckIO(tree, database), DeepEquals, []supportconfig.Finding{
+		{Path: "/sys/block/sda/queue/scheduler", Value: "noop deadline [cfq]",
+			Message: "scheduler should be one of deadline, mq-deadline, noop, none"},
+		{Path: "/sys/block/sda/queue/nr_requests", Value: "128",
+			Message: "nr_requests should be at least 256"},
+		{Path: "/sys/block/sdb/queue/read_ahead_kb", Value: "4096",
+			Message: "read_ahead_kb should be at most 1024"},
+	})
+
+	virtualization := supportconfig.IOExpectations{Schedulers: []string{"none", "cfq", "mq-deadline"}}
+	c.Assert(supportconfig.CheckIO(tree, virtualization), HasLen, 0)
+}
+
+func (cs *checksSuite) TestFindingString(c *C) {
+	f := supportconfig.Finding{Path: "/sys/block/sda/queue/nr_requests", Value: "128", Message: "nr_requests should be at least 256"}
+	c.Assert(f.String(), Equals, `/sys/block/sda/queue/nr_requests: nr_requests should be at least 256 (found "128")`)
+}
+
+func (cs *checksSuite) TestCheckMemory(c *C) {
+	tree := sampleTree(map[string]string{
+		"/sys/kernel/mm/transparent_hugepage/enabled": "[always] madvise never",
+		"/proc/sys/vm/swappiness":                     "60",
+		"/proc/sys/vm/dirty_ratio":                    "20",
+		"/proc/sys/vm/dirty_background_ratio":         "10",
+	})
+
+	expected := *supportconfig.SAPProfile.Memory
+	expected.DirtyRatio = &supportconfig.Range{Min: 10, Max: 20}
+	expected.DirtyBackgroundRatio = &supportconfig.Range{Min: 3, Max: 3}
+	c.Assert(supportconfig.CheckMemory(tree, expected), DeepEquals, []supportconfig.Finding{
+		{Path: "/sys/kernel/mm/transparent_hugepage/enabled", Value: "[always] madvise never",
+			Message: "transparent hugepages should be never"},
+		{Path: "/proc/sys/vm/swappiness", Value: "60",
+			Message: "swappiness should be between 0 and 10"},
+		{Path: "/proc/sys/vm/dirty_background_ratio", Value: "10",
+			Message: "dirty_background_ratio should be 3"},
+	})
+
+	c.Assert(supportconfig.CheckMemory(tree, supportconfig.MemoryExpectations{}), HasLen, 0)
+	c.Assert(supportconfig.SAPProfile.Extend("hana").Memory, Equals, supportconfig.SAPProfile.Memory)
+}
+
+func (cs *checksSuite) TestCPUVulnerabilities(c *C) {
+	tree := sampleTree(map[string]string{
+		"/proc/cmdline": "BOOT_IMAGE=/boot/vmlinuz root=/dev/sda2 mitigations=off quiet",
+		"/sys/devices/system/cpu/vulnerabilities/meltdown":   "Vulnerable",
+		"/sys/devices/system/cpu/vulnerabilities/spectre_v2": "Mitigation: Retpolines, IBPB: conditional",
+		"/sys/devices/system/cpu/vulnerabilities/l1tf":       "Not affected",
+		"/sys/devices/system/cpu/vulnerabilities/new_one":    "Vulnerable: Clear CPU buffers attempted, no microcode",
+	})
+
+	vulnerabilities := supportconfig.CPUVulnerabilities(tree)
+	c.Assert(vulnerabilities, HasLen, 4)
+	c.Assert(vulnerabilities[0].Name, Equals, "l1tf")
+	c.Assert(vulnerabilities[0].Vulnerable(), Equals, false)
+	c.Assert(vulnerabilities[3], DeepEquals, supportconfig.Vulnerability{
+		Name:   "spectre_v2",
+		CVEs:   []string{"CVE-2017-5715"},
+		Status: "Mitigation: Retpolines, IBPB: conditional",
+	})
+
+	c.Assert(supportconfig.CheckCPUVulnerabilities(tree), DeepEquals, []supportconfig.Finding{
+		{Path: "/proc/cmdline", Value: "BOOT_IMAGE=/boot/vmlinuz root=/dev/sda2 mitigations=off quiet",
+			Message: "CPU vulnerability mitigations should not be disabled"},
+		{Path: "/sys/devices/system/cpu/vulnerabilities/meltdown", Value: "Vulnerable",
+			Message: "meltdown should be mitigated (CVE-2017-5754)"},
+		{Path: "/sys/devices/system/cpu/vulnerabilities/new_one", Value: "Vulnerable: Clear CPU buffers attempted, no microcode",
+			Message: "new_one should be mitigated"},
+	})
+}
+
+func (cs *checksSuite) TestCheckFIPS(c *C) {
+	tree := sampleTree(map[string]string{
+		"/proc/sys/crypto/fips_enabled": "0",
+		"/proc/cmdline":                 "BOOT_IMAGE=/boot/vmlinuz root=/dev/sda2 quiet",
+		"/etc/crypto-policies/config":   "# system wide policy\n\nDEFAULT:SHA1",
+	})
+	c.Assert(supportconfig.CheckFIPS(tree), DeepEquals, []supportconfig.Finding{
+		{Path: "/proc/sys/crypto/fips_enabled", Value: "0",
+			Message: "the kernel should run in FIPS mode"},
+		{Path: "/proc/cmdline", Value: "BOOT_IMAGE=/boot/vmlinuz root=/dev/sda2 quiet",
+			Message: "the kernel should be booted with fips=1"},
+		{Path: "/etc/crypto-policies/config", Value: "DEFAULT:SHA1",
+			Message: "the crypto policy should be FIPS"},
+	})
+
+	tree = sampleTree(map[string]string{
+		"/proc/sys/crypto/fips_enabled": "1",
+		"/proc/cmdline":                 "root=/dev/sda2 fips=1",
+		"/etc/crypto-policies/config":   "FIPS:OSPP",
+	})
+	c.Assert(supportconfig.CheckFIPS(tree), HasLen, 0)
+	c.Assert(supportconfig.CheckFIPS(supportconfig.NewTree()), HasLen, 0)
+}
+
+const samplePasswords = `#==[ Configuration File ]===========================#
+# /etc/login.defs
+# password aging
+PASS_MAX_DAYS	90
+PASS_MIN_DAYS	1
+PASS_WARN_AGE	7
+ENCRYPT_METHOD SHA512
+
+#==[ Configuration File ]===========================#
+# /etc/security/pwquality.conf
+minlen = 8
+dcredit = -1
+
+#==[ Configuration File ]===========================#
+# /etc/pam.d/common-password
+password	requisite	pam_pwquality.so	minlen=12 retry=3
+password	required	pam_unix.so	use_authtok nullok shadow
+
+#==[ Configuration File ]===========================#
+# /etc/shadow
+root:$6$salt$hash:19000:0:99999:7:::
+bin:*:17000::::::
+kiosk::19100:0:99999:7:::
+old:!$6$salt$oldhash:18000:1:90:7:30:19500:
+
+`
+
+func (cs *checksSuite) TestReadPasswordPolicyBracketedControl(c *C) {
+	source := strings.Replace(samplePasswords, "password	requisite	pam_pwquality.so	minlen=12",
+		"password	[success=1 default=ignore]	pam_pwquality.so	minlen=14", 1)
+	tree, err := supportconfig.ParseTree(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	policy := supportconfig.ReadPasswordPolicy(tree)
+	c.Assert(policy.Quality, DeepEquals, map[string]string{"minlen": "14", "dcredit": "-1", "retry": "3"})
+}
+
+func (cs *checksSuite) TestReadPasswordPolicy(c *C) {
+	tree, err := supportconfig.ParseTree(strings.NewReader(samplePasswords))
+	c.Assert(err, IsNil)
+
+	shadow, found := tree.Get("/etc/shadow")
+	c.Assert(found, Equals, true)
+	c.Assert(strings.Contains(shadow, "hash"), Equals, false)
+
+	policy := supportconfig.ReadPasswordPolicy(tree)
+	c.Assert(policy.MaxDays, Equals, int64(90))
+	c.Assert(policy.MinDays, Equals, int64(1))
+	c.Assert(policy.WarnAge, Equals, int64(7))
+	c.Assert(policy.MinLength, Equals, int64(-1))
+	c.Assert(policy.EncryptMethod, Equals, "SHA512")
+	c.Assert(policy.Quality, DeepEquals, map[string]string{"minlen": "12", "dcredit": "-1", "retry": "3"})
+	c.Assert(policy.Accounts, DeepEquals, []supportconfig.AccountAging{
+		{User: "root", LastChange: 19000, MinDays: 0, MaxDays: 99999, WarnDays: 7, InactiveDays: -1, Expire: -1},
+		{User: "bin", LastChange: 17000, MinDays: -1, MaxDays: -1, WarnDays: -1, InactiveDays: -1, Expire: -1, NoPassword: true},
+		{User: "kiosk", LastChange: 19100, MinDays: 0, MaxDays: 99999, WarnDays: 7, InactiveDays: -1, Expire: -1, EmptyPassword: true},
+		{User: "old", LastChange: 18000, MinDays: 1, MaxDays: 90, WarnDays: 7, InactiveDays: 30, Expire: 19500, Locked: true},
+	})
+}
@@ -0,0 +1,264 @@
+package supportconfig
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// bannerWidth is the width supportconfig pads the section banners to
+const bannerWidth = 53
+
+// CommandNotFound is written as the output of commands that couldn't be
+// run, as supportconfig does
+const CommandNotFound = "ERROR: Command not found or not executable"
+
+// Banner returns the banner line supportconfig uses to start a section
+func Banner(section string) string {
+	banner := "#==[ " + section + " ]"
+	pad := bannerWidth - len(banner) - 1
+	if pad < 1 {
+		pad = 1
+	}
+	return banner + strings.Repeat("=", pad) + "#"
+}
+
+// Writer writes sections in the supportconfig format, so that bundles
+// readable by Parser and Splitter can be collected where supportutils is
+// not installed
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter creates a Writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write implements io.Writer, keeping the first error found
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(b)
+	w.err = err
+	return n, err
+}
+
+func (w *Writer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(w, format, args...)
+}
+
+// WriteSection writes a section with the given header line and the
+// contents of body. Embedded files (Configuration File and Log File
+// sections) are followed by a blank line as in supportconfig. An error
+// reading body is returned after the section is ended, so that what was
+// written can still be parsed.
+func (w *Writer) WriteSection(section, header string, body io.Reader) error {
+	var err error
+	w.printf("%s\n# %s\n", Banner(section), header)
+	if body != nil {
+		_, err = io.Copy(w, body)
+	}
+	if section == "Configuration File" || section == "Log File" {
+		w.printf("%s", separator)
+	} else {
+		w.printf("\n")
+	}
+	if w.err != nil {
+		return w.err
+	}
+	return err
+}
+
+// WriteFile writes a Configuration File section with the contents of the
+// file in path
+func (w *Writer) WriteFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			w.printf("%s\n# %s - %s\n\n", Banner("Configuration File"), path, FileNotFound)
+			return w.err
+		}
+		return err
+	}
+	defer f.Close()
+	return w.WriteSection("Configuration File", path, f)
+}
+
+// WriteLog writes a Log File section with the last lines of the file in
+// path, or all of it if lines is not positive
+func (w *Writer) WriteLog(path string, lines int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			w.printf("%s\n# %s - %s\n\n", Banner("Log File"), path, FileNotFound)
+			return w.err
+		}
+		return err
+	}
+	defer f.Close()
+	if lines <= 0 {
+		return w.WriteSection("Log File", path, f)
+	}
+	last, err := tail(f, lines)
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("%s - Last %d Lines", path, lines)
+	return w.WriteSection("Log File", header, bytes.NewReader(last))
+}
+
+// tail returns the last n lines of source, which can be of any length
+func tail(source io.Reader, n int) ([]byte, error) {
+	ring := make([][]byte, n)
+	count := 0
+	reader := bufio.NewReader(source)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			ring[count%n] = line
+			count++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	first := 0
+	if count > n {
+		first = count - n
+	}
+	for i := first; i < count; i++ {
+		buf.Write(ring[i%n])
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteCommand runs a command and writes a Command section with its
+// output. As in supportconfig, commands that are missing or not
+// executable are recorded with an error message as their output rather
+// than failing the collection. Nothing is written when ctx is done before
+// the command finishes.
+func (w *Writer) WriteCommand(ctx context.Context, name string, args ...string) error {
+	var output bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var exited *exec.ExitError
+	switch {
+	case err == nil, errors.As(err, &exited):
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, os.ErrNotExist), errors.Is(err, os.ErrPermission):
+		output.Reset()
+		output.WriteString(CommandNotFound + "\n")
+	default:
+		return err
+	}
+	if output.Len() > 0 && !bytes.HasSuffix(output.Bytes(), []byte("\n")) {
+		output.WriteString("\n")
+	}
+
+	header := strings.Join(append([]string{name}, args...), " ")
+	return w.WriteSection("Command", header, &output)
+}
+
+// Bundle is a selection of what to collect with WriteBundle
+type Bundle struct {
+	// Files are the paths of the files written as Configuration File
+	// sections
+	Files []string
+
+	// Logs are the paths of the files written as Log File sections,
+	// with their last LogLines lines or whole when it is not positive
+	Logs     []string
+	LogLines int
+
+	// Commands are the commands run and written as Command sections,
+	// each one the name of the program followed by its arguments
+	Commands [][]string
+}
+
+// MinimalBundle is a small selection of what supportconfig collects, with
+// what the analyzers of this package look for
+var MinimalBundle = Bundle{
+	Files: []string{
+		"/etc/os-release",
+		"/etc/sysctl.conf",
+		"/etc/selinux/config",
+		"/etc/crypto-policies/config",
+		"/proc/cmdline",
+		"/proc/meminfo",
+		"/proc/mounts",
+		"/proc/sys/net/netfilter/nf_conntrack_count",
+		"/proc/sys/net/netfilter/nf_conntrack_max",
+		"/sys/fs/selinux/enforce",
+	},
+	Logs: []string{
+		"/var/log/messages",
+		"/var/log/audit/audit.log",
+		"/var/log/cloud-init.log",
+		"/var/log/transactional-update.log",
+		"/var/log/zypp/history",
+	},
+	LogLines: 500,
+	Commands: [][]string{
+		{"uname", "-a"},
+		{"sysctl", "-a"},
+		{"ip", "addr"},
+		{"ss", "-anp"},
+		{"sestatus"},
+		{"cloud-init", "status", "--long"},
+		{"snapper", "--no-dbus", "list"},
+	},
+}
+
+// WriteBundle writes the files, logs and commands of bundle, such as
+// MinimalBundle. Failing to read a file or to run a command doesn't stop
+// the collection, and the first of these errors is returned at its end.
+// Errors writing and ctx being done stop it right away.
+func (w *Writer) WriteBundle(ctx context.Context, bundle Bundle) error {
+	var first error
+	next := func(err error) error {
+		if first == nil {
+			first = err
+		}
+		if w.err != nil {
+			return w.err
+		}
+		return ctx.Err()
+	}
+	for _, path := range bundle.Files {
+		if err := next(w.WriteFile(path)); err != nil {
+			return err
+		}
+	}
+	for _, path := range bundle.Logs {
+		if err := next(w.WriteLog(path, bundle.LogLines)); err != nil {
+			return err
+		}
+	}
+	for _, command := range bundle.Commands {
+		if len(command) == 0 {
+			continue
+		}
+		if err := next(w.WriteCommand(ctx, command[0], command[1:]...)); err != nil {
+			return err
+		}
+	}
+	return first
+}
@@ -0,0 +1,180 @@
+package supportconfig_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type collectorSuite struct {
+}
+
+var _ = Suite(&collectorSuite{})
+
+func (cs *collectorSuite) TestBanner(c *C) {
+	c.Assert(supportconfig.Banner("Command"), Equals, "#==[ Command ]======================================#")
+	c.Assert(supportconfig.Banner("Configuration File"), Equals, "#==[ Configuration File ]===========================#")
+	long := strings.Repeat("x", 60)
+	c.Assert(supportconfig.Banner(long), Equals, "#==[ "+long+" ]=#")
+}
+
+func (cs *collectorSuite) TestWriteCommand(c *C) {
+	var buf bytes.Buffer
+	w := supportconfig.NewWriter(&buf)
+	err := w.WriteCommand(context.Background(), "echo", "-n", "hello")
+	c.Assert(err, IsNil)
+	err = w.WriteCommand(context.Background(), "/nonexistent/command")
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, `#==[ Command ]======================================#
+# echo -n hello
+hello
+
+#==[ Command ]======================================#
+# /nonexistent/command
+`+supportconfig.CommandNotFound+`
+
+`)
+}
+
+func (cs *collectorSuite) TestWriteRoundTrip(c *C) {
+	dir := c.MkDir()
+	release := filepath.Join(dir, "etc/SuSE-release")
+	messages := filepath.Join(dir, "var/log/messages")
+	missing := filepath.Join(dir, "etc/missing.conf")
+	for path, content := range map[string]string{
+		release:  etcRelease,
+		messages: "one\ntwo\nthree\nfour",
+	} {
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	}
+
+	var buf bytes.Buffer
+	w := supportconfig.NewWriter(&buf)
+	c.Assert(w.WriteFile(release), IsNil)
+	c.Assert(w.WriteFile(missing), IsNil)
+	c.Assert(w.WriteLog(messages, 2), IsNil)
+	c.Assert(w.WriteCommand(context.Background(), "echo", "done"), IsNil)
+
+	var headers []string
+	p := supportconfig.NewParser()
+	p.HandleDefault(func(name, after string) (io.WriteCloser, error) {
+		headers = append(headers, name+": "+after)
+		return nil, nil
+	})
+	c.Assert(p.Parse(bytes.NewReader(buf.Bytes())), IsNil)
+	c.Assert(headers, DeepEquals, []string{
+		"Configuration File: # " + release,
+		"Configuration File: # " + missing + " - File not found",
+		"Log File: # " + messages + " - Last 2 Lines",
+		"Command: # echo done",
+	})
+
+	base := c.MkDir()
+	splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: base}}
+	c.Assert(splitter.Split(bytes.NewReader(buf.Bytes())), IsNil)
+	c.Assert(splitter.Warnings(), HasLen, 0)
+
+	b, err := ioutil.ReadFile(filepath.Join(base, release))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, etcRelease)
+	b, err = ioutil.ReadFile(filepath.Join(base, messages))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "three\nfour")
+	_, err = ioutil.ReadFile(filepath.Join(base, missing))
+	c.Assert(err, NotNil)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(b []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func (cs *collectorSuite) TestWriterError(c *C) {
+	w := supportconfig.NewWriter(failingWriter{})
+	err := w.WriteSection("Command", "/bin/date", strings.NewReader("today\n"))
+	c.Assert(err, Equals, io.ErrClosedPipe)
+}
+
+func (cs *collectorSuite) TestWriteSectionReadError(c *C) {
+	var buf bytes.Buffer
+	w := supportconfig.NewWriter(&buf)
+	body := io.MultiReader(strings.NewReader("partial\n"), &brokenReader{strings.NewReader("")})
+	err := w.WriteSection("Configuration File", "/etc/broken.conf", body)
+	c.Assert(err, Equals, io.ErrUnexpectedEOF)
+	// the section is ended and the writer can still be used
+	c.Assert(w.WriteCommand(context.Background(), "echo", "done"), IsNil)
+	c.Assert(buf.String(), Equals, `#==[ Configuration File ]===========================#
+# /etc/broken.conf
+partial
+
+
+#==[ Command ]======================================#
+# echo done
+done
+
+`)
+}
+
+func (cs *collectorSuite) TestWriteLogLongLines(c *C) {
+	path := filepath.Join(c.MkDir(), "messages")
+	long := strings.Repeat("x", 100000) + "\n"
+	c.Assert(ioutil.WriteFile(path, []byte("one\n"+long+"three\n"), 0644), IsNil)
+
+	var buf bytes.Buffer
+	w := supportconfig.NewWriter(&buf)
+	c.Assert(w.WriteLog(path, 2), IsNil)
+	c.Assert(buf.String(), Equals, supportconfig.Banner("Log File")+"\n# "+path+" - Last 2 Lines\n"+long+"three\n\n\n")
+}
+
+func (cs *collectorSuite) TestWriteCommandCanceled(c *C) {
+	var buf bytes.Buffer
+	w := supportconfig.NewWriter(&buf)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := w.WriteCommand(ctx, "echo", "hello")
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(buf.Len(), Equals, 0)
+}
+
+func (cs *collectorSuite) TestWriteBundle(c *C) {
+	dir := c.MkDir()
+	release := filepath.Join(dir, "os-release")
+	messages := filepath.Join(dir, "messages")
+	c.Assert(ioutil.WriteFile(release, []byte("NAME=SLES\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(messages, []byte("one\ntwo\nthree\n"), 0644), IsNil)
+	bundle := supportconfig.Bundle{
+		Files:    []string{release, dir},
+		Logs:     []string{messages},
+		LogLines: 2,
+		Commands: [][]string{{"echo", "done"}, {"/nonexistent/command"}},
+	}
+
+	var buf bytes.Buffer
+	w := supportconfig.NewWriter(&buf)
+	// reading the directory fails, but the rest is still collected
+	c.Assert(w.WriteBundle(context.Background(), bundle), NotNil)
+
+	var headers []string
+	p := supportconfig.NewParser()
+	p.HandleDefault(func(name, after string) (io.WriteCloser, error) {
+		headers = append(headers, name+": "+after)
+		return nil, nil
+	})
+	c.Assert(p.Parse(bytes.NewReader(buf.Bytes())), IsNil)
+	c.Assert(headers, DeepEquals, []string{
+		"Configuration File: # " + release,
+		"Configuration File: # " + dir,
+		"Log File: # " + messages + " - Last 2 Lines",
+		"Command: # echo done",
+		"Command: # /nonexistent/command",
+	})
+}
@@ -0,0 +1,139 @@
+package supportconfig
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// conntrackFull is logged by the kernel for each packet dropped because
+// the connection tracking table is full
+var conntrackFull = []byte("table full, dropping packet")
+
+// Conntrack has the state of the connection tracking table of netfilter
+type Conntrack struct {
+	// Count is the number of connections tracked, and Max how many of
+	// them can be. They are zero when not found.
+	Count int
+	Max   int
+
+	// Buckets is the size of the hash table, when found
+	Buckets int
+
+	// Drops is the number of "table full, dropping packet" messages
+	// found in the logs, and LastDrop the last of them. Messages found
+	// in more than one log, such as in dmesg and /var/log/messages, are
+	// counted once.
+	Drops    int
+	LastDrop string
+
+	// drops has the keys of the messages counted, see dropKey
+	drops map[string]bool
+}
+
+// ParseConntrack reads the sysctls of the connection tracking table, from
+// sysctl -a and the files in /proc/sys/net/netfilter, and looks for the
+// packets dropped because it was full in the logs and in the output of
+// commands such as dmesg found in the source
+func ParseConntrack(source io.Reader) (*Conntrack, error) {
+	conntrack := &Conntrack{}
+	if err := Analyze(source, ConntrackAnalyzer(conntrack)); err != nil {
+		return nil, err
+	}
+	return conntrack, nil
+}
+
+// ConntrackAnalyzer works as ParseConntrack, filling conntrack
+func ConntrackAnalyzer(conntrack *Conntrack) Analyzer {
+	return func(p *Parser) func() error {
+		tree := newTreeOf("/proc/sys/net/netfilter", "/proc/sys/net/nf_conntrack_max")
+		p.HandleSection("Configuration File", tree.fileHandler)
+		p.HandleSection("Command", tree.sysctlHandler)
+		for _, section := range []string{"Command", "Log File"} {
+			p.HandleSection(section, func(section, afterline string) (io.WriteCloser, error) {
+				return &lineWriter{line: conntrack.logLine}, nil
+			})
+		}
+		return func() error {
+			for _, sysctl := range []struct {
+				name  string
+				value *int
+			}{
+				{"nf_conntrack_count", &conntrack.Count},
+				{"nf_conntrack_max", &conntrack.Max},
+				{"nf_conntrack_buckets", &conntrack.Buckets},
+			} {
+				if value, found := tree.Get("/proc/sys/net/netfilter/" + sysctl.name); found {
+					*sysctl.value, _ = strconv.Atoi(value)
+				}
+			}
+			if conntrack.Max == 0 {
+				// older kernels only have net.nf_conntrack_max
+				if value, found := tree.Get("/proc/sys/net/nf_conntrack_max"); found {
+					conntrack.Max, _ = strconv.Atoi(value)
+				}
+			}
+			return nil
+		}
+	}
+}
+
+func (c *Conntrack) logLine(line []byte) {
+	if !bytes.Contains(line, conntrackFull) {
+		return
+	}
+	text := strings.TrimRight(string(line), "\r")
+	key := dropKey(text)
+	if c.drops[key] {
+		return
+	}
+	if c.drops == nil {
+		c.drops = make(map[string]bool)
+	}
+	c.drops[key] = true
+	c.Drops++
+	c.LastDrop = text
+}
+
+// dropKey identifies a kernel message logged in several places by its
+// kernel timestamp, as in "[12345.678]", which is the same in dmesg and
+// in the syslog, or by the whole line when it has none
+func dropKey(line string) string {
+	begin := strings.Index(line, "[")
+	if begin < 0 {
+		return line
+	}
+	end := strings.Index(line[begin:], "]")
+	if end < 0 {
+		return line
+	}
+	stamp := strings.TrimSpace(line[begin+1 : begin+end])
+	if _, err := strconv.ParseFloat(stamp, 64); err != nil || !strings.Contains(stamp, ".") {
+		return line
+	}
+	return stamp
+}
+
+// CheckConntrack reports packets dropped because the connection tracking
+// table was full, and the table being close to full when the supportconfig
+// was taken
+func CheckConntrack(conntrack *Conntrack) []Finding {
+	var findings []Finding
+	const maxPath = "/proc/sys/net/netfilter/nf_conntrack_max"
+	if conntrack.Drops > 0 {
+		value := ""
+		if conntrack.Max > 0 {
+			value = strconv.Itoa(conntrack.Max)
+		}
+		findings = append(findings, Finding{Path: maxPath, Value: value,
+			Message: fmt.Sprintf("connection tracking table was full and dropped packets (%d log messages), last: %s",
+				conntrack.Drops, conntrack.LastDrop)})
+	}
+	if conntrack.Max > 0 && conntrack.Count*10 >= conntrack.Max*9 {
+		findings = append(findings, Finding{Path: "/proc/sys/net/netfilter/nf_conntrack_count", Value: strconv.Itoa(conntrack.Count),
+			Message: fmt.Sprintf("connection tracking table should not be over 90%% of nf_conntrack_max (%d)", conntrack.Max)})
+	}
+	return findings
+}
@@ -0,0 +1,79 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type conntrackSuite struct {
+}
+
+var _ = Suite(&conntrackSuite{})
+
+const sampleConntrack = `#==[ Command ]======================================#
+# /sbin/sysctl -a
+net.netfilter.nf_conntrack_buckets = 16384
+net.netfilter.nf_conntrack_count = 65000
+net.netfilter.nf_conntrack_max = 65536
+
+#==[ Log File ]=====================================#
+# /var/log/messages - Last 3 Lines
+2023-03-09T10:00:00.000000+00:00 node kernel: [12345.678] nf_conntrack: nf_conntrack: table full, dropping packet
+2023-03-09T10:00:01.000000+00:00 node sshd[123]: Accepted publickey for root
+2023-03-09T10:00:02.000000+00:00 node kernel: [12347.000] nf_conntrack: nf_conntrack: table full, dropping packet
+
+#==[ Command ]======================================#
+# /bin/dmesg
+[12345.678] nf_conntrack: nf_conntrack: table full, dropping packet
+
+#==[ Log File ]=====================================#
+# /var/log/warn - Last 1 Lines
+2023-03-09T10:00:02.000000+00:00 node kernel: [12347.000] nf_conntrack: nf_conntrack: table full, dropping packet
+
+`
+
+func (cs *conntrackSuite) TestParseConntrack(c *C) {
+	conntrack, err := supportconfig.ParseConntrack(strings.NewReader(sampleConntrack))
+	c.Assert(err, IsNil)
+	c.Assert(conntrack.Count, Equals, 65000)
+	c.Assert(conntrack.Max, Equals, 65536)
+	c.Assert(conntrack.Buckets, Equals, 16384)
+	c.Assert(conntrack.Drops, Equals, 2)
+	c.Assert(conntrack.LastDrop, Matches, "2023-03-09T10:00:02.* table full, dropping packet")
+
+	findings := supportconfig.CheckConntrack(conntrack)
+	c.Assert(findings, HasLen, 2)
+	c.Assert(findings[0].Message, Matches, ".*dropped packets \\(2 log messages\\).*")
+	c.Assert(findings[1].Value, Equals, "65000")
+}
+
+func (cs *conntrackSuite) TestCheckConntrackHealthy(c *C) {
+	source := `#==[ Configuration File ]===========================#
+# /proc/sys/net/netfilter/nf_conntrack_count
+120
+
+#==[ Configuration File ]===========================#
+# /proc/sys/net/netfilter/nf_conntrack_max
+262144
+
+`
+	conntrack, err := supportconfig.ParseConntrack(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	c.Assert(conntrack.Count, Equals, 120)
+	c.Assert(supportconfig.CheckConntrack(conntrack), HasLen, 0)
+}
+
+func (cs *conntrackSuite) TestCheckConntrackWithoutMax(c *C) {
+	source := `#==[ Command ]======================================#
+# /bin/dmesg
+[12345.678] nf_conntrack: nf_conntrack: table full, dropping packet
+
+`
+	conntrack, err := supportconfig.ParseConntrack(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	findings := supportconfig.CheckConntrack(conntrack)
+	c.Assert(findings, HasLen, 1)
+	c.Assert(findings[0].Value, Equals, "")
+}
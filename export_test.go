@@ -0,0 +1,4 @@
+package supportconfig
+
+// NewTreeOf exposes the trees of the analyzers to the tests
+var NewTreeOf = newTreeOf
@@ -0,0 +1,160 @@
+package supportconfig
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// maxProvisioningFailures is how many of the most recent provisioning
+// failures are kept
+const maxProvisioningFailures = 100
+
+// FirstBoot has what is known about the first-boot provisioning of edge
+// and cloud images, done by cloud-init, ignition or combustion
+type FirstBoot struct {
+	// Tools are the provisioning tools found to have run, in the order
+	// they were first seen
+	Tools []string
+
+	// CloudInitStatus is the status reported by cloud-init status, such
+	// as done, running or error
+	CloudInitStatus string
+
+	// Failures are the most recent errors logged by the provisioning
+	// tools, oldest first
+	Failures []ProvisioningFailure
+}
+
+// ProvisioningFailure is an error logged by a provisioning tool
+type ProvisioningFailure struct {
+	// Tool is the provisioning tool, such as ignition
+	Tool string
+
+	// Message is the error message, without the log prefix
+	Message string
+}
+
+var (
+	cloudInitLogRe = regexp.MustCompile(`\[(ERROR|CRITICAL|WARNING)\]: (.*)$`)
+	firstBootRe    = regexp.MustCompile(`\s(ignition|combustion)(?:\[\d+\])?: (.*)$`)
+)
+
+// ParseFirstBoot reads the status and the log of cloud-init and the
+// messages of ignition and combustion found in the journal of the source
+func ParseFirstBoot(source io.Reader) (*FirstBoot, error) {
+	boot := &FirstBoot{}
+	if err := Analyze(source, FirstBootAnalyzer(boot)); err != nil {
+		return nil, err
+	}
+	return boot, nil
+}
+
+// FirstBootAnalyzer works as ParseFirstBoot, filling boot
+func FirstBootAnalyzer(boot *FirstBoot) Analyzer {
+	return func(p *Parser) func() error {
+		p.HandleSection("Command", boot.commandHandler)
+		p.HandleSection("Log File", boot.logHandler)
+		return noDone
+	}
+}
+
+// Failed says whether any of the provisioning tools failed
+func (f *FirstBoot) Failed() bool {
+	return f.CloudInitStatus == "error" || len(f.Failures) > 0
+}
+
+func (f *FirstBoot) commandHandler(section, afterline string) (io.WriteCloser, error) {
+	fields := strings.Fields(strings.TrimPrefix(afterline, "# "))
+	if len(fields) >= 2 && path.Base(fields[0]) == "cloud-init" && fields[1] == "status" {
+		return &treeWriter{done: f.setCloudInitStatus}, nil
+	}
+	return &lineWriter{line: f.journalLine}, nil
+}
+
+func (f *FirstBoot) logHandler(section, afterline string) (io.WriteCloser, error) {
+	name, err := afterlineToPath(strings.TrimPrefix(afterline, "# "))
+	if err != nil {
+		return nil, err
+	}
+	if path.Clean(name) == "/var/log/cloud-init.log" {
+		return &lineWriter{line: f.cloudInitLine}, nil
+	}
+	return &lineWriter{line: f.journalLine}, nil
+}
+
+// setCloudInitStatus reads the output of cloud-init status --long, whose
+// errors are listed one per line after "errors:"
+func (f *FirstBoot) setCloudInitStatus(body []byte) {
+	f.addTool("cloud-init")
+	inErrors := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if inErrors && strings.HasPrefix(trimmed, "- ") {
+			f.addFailure("cloud-init", strings.TrimPrefix(trimmed, "- "))
+			continue
+		}
+		inErrors = false
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		switch strings.TrimSpace(line[:idx]) {
+		case "status":
+			f.CloudInitStatus = strings.TrimSpace(line[idx+1:])
+		case "errors":
+			inErrors = true
+		}
+	}
+}
+
+// cloudInitLine looks for errors in lines of /var/log/cloud-init.log.
+// The modules that fail are only logged as warnings.
+func (f *FirstBoot) cloudInitLine(line []byte) {
+	f.addTool("cloud-init")
+	found := cloudInitLogRe.FindSubmatch(line)
+	if found == nil {
+		return
+	}
+	message := strings.TrimRight(string(found[2]), "\r")
+	if string(found[1]) == "WARNING" && !strings.Contains(strings.ToLower(message), "fail") {
+		return
+	}
+	f.addFailure("cloud-init", message)
+}
+
+// journalLine looks for the messages of ignition and combustion in the
+// lines of the journal
+func (f *FirstBoot) journalLine(line []byte) {
+	if !bytes.Contains(line, []byte("ignition")) && !bytes.Contains(line, []byte("combustion")) {
+		return
+	}
+	found := firstBootRe.FindSubmatch(line)
+	if found == nil {
+		return
+	}
+	tool, message := string(found[1]), strings.TrimRight(string(found[2]), "\r")
+	f.addTool(tool)
+	lower := strings.ToLower(message)
+	if strings.Contains(lower, "error") || strings.Contains(lower, "fail") {
+		f.addFailure(tool, message)
+	}
+}
+
+func (f *FirstBoot) addTool(tool string) {
+	if !containsString(f.Tools, tool) {
+		f.Tools = append(f.Tools, tool)
+	}
+}
+
+func (f *FirstBoot) addFailure(tool, message string) {
+	f.Failures = append(f.Failures, ProvisioningFailure{Tool: tool, Message: message})
+	if len(f.Failures) > maxProvisioningFailures {
+		f.Failures = f.Failures[1:]
+	}
+}
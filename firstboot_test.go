@@ -0,0 +1,68 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type firstBootSuite struct {
+}
+
+var _ = Suite(&firstBootSuite{})
+
+const sampleFirstBoot = `#==[ Command ]======================================#
+# /usr/bin/cloud-init status --long
+status: error
+extended_status: error - done
+boot_status_code: enabled-by-generator
+detail:
+DataSourceNoCloud [seed=/dev/sr0][dsmode=net]
+errors:
+	- ('write_files', PermissionError(13, 'Permission denied'))
+recoverable_errors: {}
+
+#==[ Log File ]=====================================#
+# /var/log/cloud-init.log - Last 4 Lines
+2023-03-09 10:00:00,100 - util.py[DEBUG]: Running module write_files
+2023-03-09 10:00:00,200 - util.py[WARNING]: Running module write_files (<module 'cloudinit.config.cc_write_files'>) failed
+2023-03-09 10:00:00,300 - util.py[WARNING]: No datasource user-data found
+2023-03-09 10:00:00,400 - handlers.py[ERROR]: Unable to read /etc/hosts.tmpl
+
+#==[ Command ]======================================#
+# /usr/bin/journalctl --no-pager --boot 0
+Mar 09 10:00:00 localhost ignition[412]: Ignition 2.14.0
+Mar 09 10:00:01 localhost ignition[412]: CRITICAL : Ignition failed: failed to fetch config: resource not found
+Mar 09 10:00:02 localhost combustion[501]: Found configuration in /dev/sr0
+Mar 09 10:00:03 localhost combustion[501]: script exited with error code 1
+Mar 09 10:00:04 localhost systemd[1]: Started Combustion.
+
+`
+
+func (fs *firstBootSuite) TestParseFirstBoot(c *C) {
+	boot, err := supportconfig.ParseFirstBoot(strings.NewReader(sampleFirstBoot))
+	c.Assert(err, IsNil)
+	c.Assert(boot.Failed(), Equals, true)
+	c.Assert(boot.CloudInitStatus, Equals, "error")
+	c.Assert(boot.Tools, DeepEquals, []string{"cloud-init", "ignition", "combustion"})
+	c.Assert(boot.Failures, DeepEquals, []supportconfig.ProvisioningFailure{
+		{Tool: "cloud-init", Message: "('write_files', PermissionError(13, 'Permission denied'))"},
+		{Tool: "cloud-init", Message: "Running module write_files (<module 'cloudinit.config.cc_write_files'>) failed"},
+		{Tool: "cloud-init", Message: "Unable to read /etc/hosts.tmpl"},
+		{Tool: "ignition", Message: "CRITICAL : Ignition failed: failed to fetch config: resource not found"},
+		{Tool: "combustion", Message: "script exited with error code 1"},
+	})
+}
+
+func (fs *firstBootSuite) TestParseFirstBootClean(c *C) {
+	source := `#==[ Command ]======================================#
+# /usr/bin/cloud-init status
+status: done
+
+`
+	boot, err := supportconfig.ParseFirstBoot(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	c.Assert(boot.Failed(), Equals, false)
+	c.Assert(boot.Tools, DeepEquals, []string{"cloud-init"})
+}
@@ -1,7 +1,13 @@
 module github.com/bhdn/go-supportconfig
 
+go 1.23
+
 require (
-	github.com/kr/pretty v0.1.0 // indirect
 	github.com/opencontainers/runc v0.1.1
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127
 )
+
+require (
+	github.com/kr/pretty v0.1.0 // indirect
+	github.com/kr/text v0.1.0 // indirect
+)
@@ -0,0 +1,440 @@
+package supportconfig
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshot is a snapshot as listed by snapper list
+type Snapshot struct {
+	Number      int
+	Type        string
+	Pre         int
+	Date        string
+	User        string
+	Cleanup     string
+	Description string
+	Userdata    string
+
+	// Default says whether the snapshot is the one booted by default,
+	// and Active whether it is the one mounted as root. snapper marks
+	// them with "+" and "-", or with "*" when it is both.
+	Default bool
+	Active  bool
+}
+
+// ParseSnapperList parses the table written by snapper list, either with
+// ASCII or with box drawing separators
+func ParseSnapperList(source io.Reader) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	var columns []string
+
+	err := eachLine(source, func(line string) {
+		line = strings.Replace(line, "│", "|", -1)
+		if !strings.Contains(line, "|") {
+			return
+		}
+		fields := strings.Split(line, "|")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if columns == nil {
+			columns = fields
+			return
+		}
+		if strings.Trim(line, "-+|─┼ ") == "" {
+			return
+		}
+
+		var snapshot Snapshot
+		for i, column := range columns {
+			if i >= len(fields) {
+				break
+			}
+			value := fields[i]
+			switch column {
+			case "#":
+				switch {
+				case strings.HasSuffix(value, "*"):
+					snapshot.Default, snapshot.Active = true, true
+				case strings.HasSuffix(value, "+"):
+					snapshot.Default = true
+				case strings.HasSuffix(value, "-"):
+					snapshot.Active = true
+				}
+				snapshot.Number, _ = strconv.Atoi(strings.TrimRight(value, "+*-"))
+			case "Type":
+				snapshot.Type = value
+			case "Pre #":
+				snapshot.Pre, _ = strconv.Atoi(value)
+			case "Date":
+				snapshot.Date = value
+			case "User":
+				snapshot.User = value
+			case "Cleanup":
+				snapshot.Cleanup = value
+			case "Description":
+				snapshot.Description = value
+			case "Userdata":
+				snapshot.Userdata = value
+			}
+		}
+		snapshots = append(snapshots, snapshot)
+	})
+	return snapshots, err
+}
+
+// TransactionalUpdate is a run of transactional-update, from its log
+type TransactionalUpdate struct {
+	// Started is the time the run started, as logged
+	Started string
+
+	// Options are the options the run was called with, such as dup
+	Options string
+
+	// Snapshot is the new default snapshot created, or zero
+	Snapshot int
+
+	// Finished says whether the run got to its end
+	Finished bool
+
+	// Errors are the errors logged
+	Errors []string
+}
+
+var (
+	tuLineRe     = regexp.MustCompile(`^(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d) (.*)$`)
+	tuSnapshotRe = regexp.MustCompile(`New default snapshot is #(\d+)`)
+)
+
+// ParseTransactionalUpdateLog parses the runs found in the log of
+// transactional-update
+func ParseTransactionalUpdateLog(source io.Reader) ([]TransactionalUpdate, error) {
+	var updates []TransactionalUpdate
+	var current *TransactionalUpdate
+
+	err := eachLine(source, func(line string) {
+		date, message := "", line
+		if found := tuLineRe.FindStringSubmatch(message); found != nil {
+			date, message = found[1], found[2]
+		}
+		switch {
+		case strings.HasPrefix(message, "transactional-update ") && strings.HasSuffix(message, " started"):
+			updates = append(updates, TransactionalUpdate{Started: date})
+			current = &updates[len(updates)-1]
+		case current == nil:
+		case strings.HasPrefix(message, "Options: "):
+			current.Options = strings.TrimPrefix(message, "Options: ")
+		case strings.HasPrefix(message, "transactional-update finished"):
+			current.Finished = true
+		case strings.HasPrefix(message, "ERROR:"):
+			current.Errors = append(current.Errors, strings.TrimSpace(strings.TrimPrefix(message, "ERROR:")))
+		default:
+			if found := tuSnapshotRe.FindStringSubmatch(message); found != nil {
+				current.Snapshot, _ = strconv.Atoi(found[1])
+			}
+		}
+	})
+	return updates, err
+}
+
+// Time returns the time the run started, or the zero time when unknown
+func (u TransactionalUpdate) Time() time.Time {
+	t, _ := time.Parse("2006-01-02 15:04:05", u.Started)
+	return t
+}
+
+// ImmutableOS has what is known about the transactional systems, such as
+// SLE Micro, from a supportconfig
+type ImmutableOS struct {
+	// ReadOnlyRoot says whether the root filesystem is mounted read
+	// only, as on transactional systems
+	ReadOnlyRoot bool
+
+	// Snapshots are the snapshots of the root filesystem
+	Snapshots []Snapshot
+
+	// Updates are the runs of transactional-update
+	Updates []TransactionalUpdate
+
+	// History are the package changes done by zypper
+	History []ZypperEvent
+}
+
+// Rollbacks returns the rollbacks found in the snapshots, with the
+// package changes they undid
+func (i *ImmutableOS) Rollbacks() []Rollback {
+	return DetectRollbacks(i.Snapshots, i.Updates, i.History)
+}
+
+// ParseImmutable reads the mounts, the snapper snapshots, the log of
+// transactional-update and the zypper history found in the source
+func ParseImmutable(source io.Reader) (*ImmutableOS, error) {
+	immutable := &ImmutableOS{}
+	if err := Analyze(source, ImmutableAnalyzer(immutable)); err != nil {
+		return nil, err
+	}
+	return immutable, nil
+}
+
+// ImmutableAnalyzer works as ParseImmutable, filling immutable
+func ImmutableAnalyzer(immutable *ImmutableOS) Analyzer {
+	return func(p *Parser) func() error {
+		var err error
+		fail := func(e error) {
+			if e != nil && err == nil {
+				err = e
+			}
+		}
+		p.HandleSection("Configuration File", func(section, afterline string) (io.WriteCloser, error) {
+			name, _ := afterlineToPath(strings.TrimPrefix(afterline, "# "))
+			if path.Clean(name) != "/proc/mounts" {
+				return nil, nil
+			}
+			return &treeWriter{done: immutable.setMounts}, nil
+		})
+		p.HandleSection("Command", func(section, afterline string) (io.WriteCloser, error) {
+			fields := strings.Fields(strings.TrimPrefix(afterline, "# "))
+			if len(fields) == 0 || path.Base(fields[0]) != "snapper" || !containsString(fields, "list") {
+				return nil, nil
+			}
+			return &treeWriter{done: func(body []byte) {
+				snapshots, e := ParseSnapperList(bytes.NewReader(body))
+				if e == nil {
+					immutable.Snapshots = snapshots
+				}
+				fail(e)
+			}}, nil
+		})
+		p.HandleSection("Log File", func(section, afterline string) (io.WriteCloser, error) {
+			name, _ := afterlineToPath(strings.TrimPrefix(afterline, "# "))
+			switch path.Clean(name) {
+			case "/var/log/transactional-update.log":
+				return &treeWriter{done: func(body []byte) {
+					updates, e := ParseTransactionalUpdateLog(bytes.NewReader(body))
+					if e == nil {
+						immutable.Updates = updates
+					}
+					fail(e)
+				}}, nil
+			case "/var/log/zypp/history":
+				return &treeWriter{done: func(body []byte) {
+					history, e := ParseZypperHistory(bytes.NewReader(body))
+					if e == nil {
+						immutable.History = history
+					}
+					fail(e)
+				}}, nil
+			}
+			return nil, nil
+		})
+		return func() error {
+			return err
+		}
+	}
+}
+
+// setMounts looks for the options of the root filesystem in the contents
+// of /proc/mounts
+func (i *ImmutableOS) setMounts(body []byte) {
+	for _, line := range strings.Split(string(body), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[1] == "/" {
+			i.ReadOnlyRoot = containsString(strings.Split(fields[3], ","), "ro")
+		}
+	}
+}
+
+// eachLine calls fn with every line read from source, without its line
+// break. Unlike bufio.Scanner, it has no limit on the length of the lines,
+// as logs can have very long ones.
+func eachLine(source io.Reader, fn func(line string)) error {
+	reader := bufio.NewReader(source)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			fn(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ZypperEvent is a package installed or removed, from the zypper history
+type ZypperEvent struct {
+	Date    time.Time
+	Action  string
+	Package string
+	Version string
+}
+
+// ParseZypperHistory parses the install and remove entries of the zypper
+// history, /var/log/zypp/history
+func ParseZypperHistory(source io.Reader) ([]ZypperEvent, error) {
+	var events []ZypperEvent
+	err := eachLine(source, func(line string) {
+		fields := strings.Split(line, "|")
+		if len(fields) < 4 {
+			return
+		}
+		action := strings.TrimSpace(fields[1])
+		if action != "install" && action != "remove" {
+			return
+		}
+		date, err := time.Parse("2006-01-02 15:04:05", fields[0])
+		if err != nil {
+			return
+		}
+		events = append(events, ZypperEvent{
+			Date:    date,
+			Action:  action,
+			Package: fields[2],
+			Version: fields[3],
+		})
+	})
+	return events, err
+}
+
+// snapshotDateLayouts are the formats of the dates listed by the versions
+// of snapper
+var snapshotDateLayouts = []string{
+	time.ANSIC,
+	"2006-01-02 15:04:05",
+	"Mon 02 Jan 2006 03:04:05 PM MST",
+}
+
+// Time returns the date of the snapshot, or the zero time when unknown
+func (s Snapshot) Time() time.Time {
+	for _, layout := range snapshotDateLayouts {
+		if t, err := time.Parse(layout, s.Date); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Rollback is a rollback of the root filesystem to an older snapshot,
+// which explains changes disappearing after a reboot
+type Rollback struct {
+	// Target is the snapshot rolled back to, and Default the writable
+	// copy of it that became the default one, or Target itself when it
+	// was made the default snapshot as it is
+	Target  int
+	Default int
+
+	// Date is when the rollback was done, when known
+	Date string
+
+	// Undone are the package changes done between the target snapshot
+	// and the rollback, which were lost. It is empty when the target
+	// snapshot or its date are unknown.
+	Undone []ZypperEvent
+}
+
+var writableCopyRe = regexp.MustCompile(`^writable copy of #(\d+)$`)
+
+// recentRollback is how much older than the newest snapshot, update or
+// history entry a rollback can be to be reported by DetectRollbacks
+const recentRollback = 30 * 24 * time.Hour
+
+// DetectRollbacks finds the recent rollbacks of the root filesystem, from
+// the writable copies of snapshots made by snapper rollback, the runs of
+// transactional-update rollback, and the default snapshot being older
+// than the active one. Rollbacks done more than 30 days before the newest
+// snapshot, update or history entry are left out. The package changes of
+// the history done after the target snapshot and before the rollback are
+// reported as undone.
+func DetectRollbacks(snapshots []Snapshot, updates []TransactionalUpdate, history []ZypperEvent) []Rollback {
+	var rollbacks []Rollback
+	var newest time.Time
+	byNumber := make(map[int]Snapshot)
+	active, current := 0, 0
+	for _, snapshot := range snapshots {
+		byNumber[snapshot.Number] = snapshot
+		if t := snapshot.Time(); t.After(newest) {
+			newest = t
+		}
+		if snapshot.Active {
+			active = snapshot.Number
+		}
+		if snapshot.Default {
+			current = snapshot.Number
+		}
+	}
+	for _, update := range updates {
+		if t := update.Time(); t.After(newest) {
+			newest = t
+		}
+	}
+	for _, event := range history {
+		if event.Date.After(newest) {
+			newest = event.Date
+		}
+	}
+
+	undone := func(target Snapshot, until time.Time) []ZypperEvent {
+		var events []ZypperEvent
+		from := target.Time()
+		if from.IsZero() {
+			// the target was deleted or its date is unknown, so
+			// there is no telling what was undone
+			return nil
+		}
+		for _, event := range history {
+			if event.Date.After(from) && (until.IsZero() || event.Date.Before(until)) {
+				events = append(events, event)
+			}
+		}
+		return events
+	}
+	add := func(rollback Rollback, date time.Time) {
+		if !date.IsZero() && date.Before(newest.Add(-recentRollback)) {
+			return
+		}
+		for _, found := range rollbacks {
+			if found.Target == rollback.Target {
+				return
+			}
+		}
+		rollback.Undone = undone(byNumber[rollback.Target], date)
+		rollbacks = append(rollbacks, rollback)
+	}
+
+	for _, snapshot := range snapshots {
+		found := writableCopyRe.FindStringSubmatch(snapshot.Description)
+		if found == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(found[1])
+		add(Rollback{Target: number, Default: snapshot.Number, Date: snapshot.Date}, snapshot.Time())
+	}
+	for _, update := range updates {
+		fields := strings.Fields(update.Options)
+		if len(fields) == 0 || fields[0] != "rollback" {
+			continue
+		}
+		// without a number, the active snapshot becomes the default
+		target := active
+		if len(fields) > 1 {
+			target, _ = strconv.Atoi(fields[1])
+		}
+		if target > 0 {
+			add(Rollback{Target: target, Default: target, Date: update.Started}, update.Time())
+		}
+	}
+	if current > 0 && active > 0 && current < active {
+		// set back by transactional-update rollback, to be booted next
+		add(Rollback{Target: current, Default: current}, time.Time{})
+	}
+	return rollbacks
+}
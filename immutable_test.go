@@ -0,0 +1,186 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type immutableSuite struct {
+}
+
+var _ = Suite(&immutableSuite{})
+
+const sampleSnapperList = ` # | Type   | Pre # | Date                     | User | Used Space | Cleanup | Description           | Userdata
+---+--------+-------+--------------------------+------+------------+---------+-----------------------+--------------
+0  | single |       |                          | root |            |         | current               |
+1  | single |       | Mon Mar  6 10:00:00 2023 | root | 1.00 MiB   | number  | first root filesystem |
+2- | single |       | Thu Mar  9 10:00:01 2023 | root | 12.00 MiB  | number  | Snapshot Update of #1 | important=yes
+3+ | single |       | Fri Mar 10 08:00:00 2023 | root | 16.00 KiB  |         | Snapshot Update of #2 |
+`
+
+const sampleTransactionalUpdateLog = `2023-03-09 10:00:01 transactional-update 4.1.3 started
+2023-03-09 10:00:01 Options: dup
+2023-03-09 10:00:02 Separate /var detected.
+2023-03-09 10:05:00 New default snapshot is #2 (/.snapshots/2/snapshot).
+2023-03-09 10:05:00 transactional-update finished
+2023-03-10 08:00:00 transactional-update 4.1.3 started
+2023-03-10 08:00:00 Options: pkg install vim
+2023-03-10 08:00:03 ERROR: zypper install on snapshot 3 failed
+`
+
+func (is *immutableSuite) TestParseSnapperList(c *C) {
+	snapshots, err := supportconfig.ParseSnapperList(strings.NewReader(sampleSnapperList))
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, HasLen, 4)
+	c.Assert(snapshots[2], DeepEquals, supportconfig.Snapshot{
+		Number:      2,
+		Type:        "single",
+		Date:        "Thu Mar  9 10:00:01 2023",
+		User:        "root",
+		Cleanup:     "number",
+		Description: "Snapshot Update of #1",
+		Userdata:    "important=yes",
+		Active:      true,
+	})
+	c.Assert(snapshots[3].Default, Equals, true)
+	c.Assert(snapshots[3].Active, Equals, false)
+
+	boxed := strings.NewReader(" # │ Type   │ Description\n───┼────────┼────────────\n0  │ single │ current\n1* │ single │ first root filesystem\n")
+	snapshots, err = supportconfig.ParseSnapperList(boxed)
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, HasLen, 2)
+	c.Assert(snapshots[1].Number, Equals, 1)
+	c.Assert(snapshots[1].Default && snapshots[1].Active, Equals, true)
+	c.Assert(snapshots[1].Description, Equals, "first root filesystem")
+}
+
+func (is *immutableSuite) TestParseTransactionalUpdateLog(c *C) {
+	updates, err := supportconfig.ParseTransactionalUpdateLog(strings.NewReader(sampleTransactionalUpdateLog))
+	c.Assert(err, IsNil)
+	c.Assert(updates, DeepEquals, []supportconfig.TransactionalUpdate{
+		{Started: "2023-03-09 10:00:01", Options: "dup", Snapshot: 2, Finished: true},
+		{Started: "2023-03-10 08:00:00", Options: "pkg install vim", Errors: []string{"zypper install on snapshot 3 failed"}},
+	})
+}
+
+func (is *immutableSuite) TestParseImmutable(c *C) {
+	source := `#==[ Configuration File ]===========================#
+# /proc/mounts
+/dev/vda3 / btrfs ro,relatime,subvol=/@/.snapshots/2/snapshot 0 0
+/dev/vda3 /var btrfs rw,relatime,subvol=/@/var 0 0
+
+#==[ Command ]======================================#
+# /usr/bin/snapper --no-dbus list
+` + sampleSnapperList + `
+#==[ Log File ]=====================================#
+# /var/log/transactional-update.log
+` + sampleTransactionalUpdateLog + `
+`
+	immutable, err := supportconfig.ParseImmutable(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	c.Assert(immutable.ReadOnlyRoot, Equals, true)
+	c.Assert(immutable.Snapshots, HasLen, 4)
+	c.Assert(immutable.Updates, HasLen, 2)
+}
+
+const sampleZypperHistory = `# 2023-03-09 10:03:00 vim-9.0.1386-1.1.x86_64.rpm installed ok
+2023-03-09 10:03:00|install|vim|9.0.1386-1.1|x86_64||repo-oss|abc|
+2023-03-10 08:30:00|remove |nano|7.2-1.1|x86_64|root@node|
+2023-03-10 08:31:00|command|root@node|'zypper' 'in' 'htop'|
+2023-03-10 08:31:10|install|htop|3.2.2-1.1|x86_64|root@node|repo-oss|def|
+2023-03-12 09:00:00|install|tmux|3.3a-1.1|x86_64|root@node|repo-oss|ghi|
+`
+
+func (is *immutableSuite) TestParseZypperHistory(c *C) {
+	events, err := supportconfig.ParseZypperHistory(strings.NewReader(sampleZypperHistory))
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 4)
+	c.Assert(events[1].Action, Equals, "remove")
+	c.Assert(events[1].Package, Equals, "nano")
+	c.Assert(events[1].Date.Format("2006-01-02 15:04:05"), Equals, "2023-03-10 08:30:00")
+}
+
+func (is *immutableSuite) TestParseZypperHistoryLongLines(c *C) {
+	long := "2023-03-10 08:29:00|command|root@node|'zypper' 'in'" + strings.Repeat(" 'pkg'", 20000) + "|\n"
+	events, err := supportconfig.ParseZypperHistory(strings.NewReader(long + sampleZypperHistory))
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 4)
+}
+
+func (is *immutableSuite) TestDetectRollbacks(c *C) {
+	history, err := supportconfig.ParseZypperHistory(strings.NewReader(sampleZypperHistory))
+	c.Assert(err, IsNil)
+	snapshots, err := supportconfig.ParseSnapperList(strings.NewReader(sampleSnapperList +
+		"4  | single |       | Sat Mar 11 12:00:00 2023 | root | 16.00 KiB  | number  | rollback backup of #3 | important=yes\n" +
+		"5* | single |       | Sat Mar 11 12:00:01 2023 | root | 16.00 KiB  |         | writable copy of #2   |\n"))
+	c.Assert(err, IsNil)
+	snapshots[2].Active, snapshots[3].Default = false, false
+
+	rollbacks := supportconfig.DetectRollbacks(snapshots, nil, history)
+	c.Assert(rollbacks, HasLen, 1)
+	c.Assert(rollbacks[0].Target, Equals, 2)
+	c.Assert(rollbacks[0].Default, Equals, 5)
+	c.Assert(rollbacks[0].Date, Equals, "Sat Mar 11 12:00:01 2023")
+	var undone []string
+	for _, event := range rollbacks[0].Undone {
+		undone = append(undone, event.Action+" "+event.Package)
+	}
+	c.Assert(undone, DeepEquals, []string{"install vim", "remove nano", "install htop"})
+
+	// old rollbacks are not reported
+	later := append(snapshots, supportconfig.Snapshot{Number: 6, Date: "Mon May  1 10:00:00 2023"})
+	c.Assert(supportconfig.DetectRollbacks(later, nil, history), HasLen, 0)
+
+	// nothing is known to be undone when the target was deleted
+	snapshots, err = supportconfig.ParseSnapperList(strings.NewReader(sampleSnapperList +
+		"5* | single |       | Sat Mar 11 12:00:01 2023 | root | 16.00 KiB  |         | writable copy of #9   |\n"))
+	c.Assert(err, IsNil)
+	snapshots[2].Active, snapshots[3].Default = false, false
+	rollbacks = supportconfig.DetectRollbacks(snapshots, nil, history)
+	c.Assert(rollbacks, HasLen, 1)
+	c.Assert(rollbacks[0].Target, Equals, 9)
+	c.Assert(rollbacks[0].Undone, HasLen, 0)
+}
+
+func (is *immutableSuite) TestDetectTransactionalRollbacks(c *C) {
+	history, err := supportconfig.ParseZypperHistory(strings.NewReader(sampleZypperHistory))
+	c.Assert(err, IsNil)
+
+	// transactional-update rollback makes an older snapshot the default
+	snapshots, err := supportconfig.ParseSnapperList(strings.NewReader(sampleSnapperList))
+	c.Assert(err, IsNil)
+	snapshots[3].Default, snapshots[1].Default = false, true
+	rollbacks := supportconfig.DetectRollbacks(snapshots, nil, history)
+	c.Assert(rollbacks, HasLen, 1)
+	c.Assert(rollbacks[0].Target, Equals, 1)
+	c.Assert(rollbacks[0].Undone, HasLen, 4)
+
+	updates, err := supportconfig.ParseTransactionalUpdateLog(strings.NewReader(sampleTransactionalUpdateLog +
+		"2023-03-11 09:00:00 transactional-update 4.1.3 started\n" +
+		"2023-03-11 09:00:00 Options: rollback 1\n" +
+		"2023-03-11 09:00:01 transactional-update finished\n"))
+	c.Assert(err, IsNil)
+	rollbacks = supportconfig.DetectRollbacks(snapshots, updates, history)
+	c.Assert(rollbacks, HasLen, 1)
+	c.Assert(rollbacks[0].Target, Equals, 1)
+	c.Assert(rollbacks[0].Date, Equals, "2023-03-11 09:00:00")
+	c.Assert(rollbacks[0].Undone, HasLen, 3)
+}
+
+func (is *immutableSuite) TestDetectRollbacksStock(c *C) {
+	// a SLES system never rolled back, with the snapshots of zypper
+	// made after the first one, which is the default
+	snapshots, err := supportconfig.ParseSnapperList(strings.NewReader(` # | Type   | Pre # | Date                     | User | Cleanup | Description           | Userdata
+---+--------+-------+--------------------------+------+---------+-----------------------+--------------
+0  | single |       |                          | root |         | current               |
+1* | single |       | Mon Mar  6 10:00:00 2023 | root |         | first root filesystem |
+2  | pre    |       | Thu Mar  9 10:02:59 2023 | root | number  | zypp(zypper)          | important=no
+3  | post   |     2 | Thu Mar  9 10:03:01 2023 | root | number  |                       | important=no
+`))
+	c.Assert(err, IsNil)
+	history, err := supportconfig.ParseZypperHistory(strings.NewReader(sampleZypperHistory))
+	c.Assert(err, IsNil)
+	c.Assert(supportconfig.DetectRollbacks(snapshots, nil, history), HasLen, 0)
+}
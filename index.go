@@ -0,0 +1,123 @@
+package supportconfig
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/opencontainers/runc/libcontainer/utils"
+)
+
+// IndexEntry describes where a section is in the source
+type IndexEntry struct {
+	// Section is the name of the section, as found in the banner
+	Section string
+
+	// Header is the line following the banner
+	Header string
+
+	// Offset and Bytes are the byte range of the section body
+	Offset int64
+	Bytes  int64
+
+	// Lines is the number of lines of the section body
+	Lines int64
+}
+
+// Index has the position of every section of a seekable source, so that
+// sections can be read without scanning the source again
+type Index struct {
+	// Sections are the sections in the order they appear in the source
+	Sections []IndexEntry
+
+	source io.ReadSeeker
+}
+
+// BuildIndex reads the whole source, from its beginning, recording where
+// each section is
+func BuildIndex(source io.ReadSeeker) (*Index, error) {
+	if _, err := source.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	index := &Index{source: source}
+
+	p := NewParser()
+	p.handleUntimedStats(func(stats SectionStats) error {
+		index.Sections = append(index.Sections, IndexEntry{
+			Section: stats.Section,
+			Header:  stats.Header,
+			Offset:  stats.Offset,
+			Bytes:   stats.Bytes,
+			Lines:   stats.Lines,
+		})
+		return nil
+	})
+	if err := p.Parse(source); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Open returns a reader for the body of a section, found by the path of
+// the file embedded in it, such as /var/log/messages, or by its name. As
+// in Splitter, the last section with a path is used and the separator
+// following the file is left out, while sections found by name are the
+// first ones with it and are read as they are.
+//
+// When the source isn't an io.ReaderAt, the readers returned share its
+// position, so only one of them can be read at a time.
+func (index *Index) Open(name string) (io.ReadCloser, error) {
+	found := -1
+	trim := false
+	clean := utils.CleanPath(name)
+	for i, entry := range index.Sections {
+		if sectionPath(KindOf(entry.Section), entry.Header) == clean {
+			found, trim = i, true
+		}
+	}
+	if found < 0 {
+		for i, entry := range index.Sections {
+			if entry.Section == name {
+				found = i
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	entry := index.Sections[found]
+	size := entry.Bytes
+	if trim {
+		tail := int64(len(separator))
+		if tail > size {
+			tail = size
+		}
+		b := make([]byte, tail)
+		if _, err := index.readAt(b, entry.Offset+size-tail); err != nil {
+			return nil, err
+		}
+		if bytes.Equal(b, []byte(separator)) {
+			size -= tail
+		}
+	}
+	if at, ok := index.source.(io.ReaderAt); ok {
+		return io.NopCloser(io.NewSectionReader(at, entry.Offset, size)), nil
+	}
+	if _, err := index.source.Seek(entry.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(io.LimitReader(index.source, size)), nil
+}
+
+// readAt reads len(b) bytes of the source at the given offset
+func (index *Index) readAt(b []byte, offset int64) (int, error) {
+	if at, ok := index.source.(io.ReaderAt); ok {
+		return at.ReadAt(b, offset)
+	}
+	if _, err := index.source.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(index.source, b)
+}
@@ -0,0 +1,88 @@
+package supportconfig_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type indexSuite struct {
+}
+
+var _ = Suite(&indexSuite{})
+
+func (is *indexSuite) TestBuildIndex(c *C) {
+	source := strings.NewReader(sampleMultipleFiles)
+	// the index is built from the beginning of the source
+	source.Seek(100, io.SeekStart)
+	index, err := supportconfig.BuildIndex(source)
+	c.Assert(err, IsNil)
+	c.Assert(index.Sections, HasLen, 5)
+
+	entry := index.Sections[3]
+	c.Assert(entry.Section, Equals, "Configuration File")
+	c.Assert(entry.Header, Equals, "# /etc/os-release")
+	c.Assert(entry.Lines, Equals, int64(strings.Count(osRelease+UglyExtraNewlines, "\n")))
+	body := sampleMultipleFiles[entry.Offset : entry.Offset+entry.Bytes]
+	c.Assert(body, Equals, osRelease+UglyExtraNewlines)
+}
+
+// seekOnly hides the ReadAt method of a reader
+type seekOnly struct {
+	io.ReadSeeker
+}
+
+func (is *indexSuite) TestIndexOpen(c *C) {
+	for _, source := range []io.ReadSeeker{
+		strings.NewReader(sampleMultipleFiles),
+		seekOnly{strings.NewReader(sampleMultipleFiles)},
+	} {
+		index, err := supportconfig.BuildIndex(source)
+		c.Assert(err, IsNil)
+
+		r, err := index.Open("/etc/os-release")
+		c.Assert(err, IsNil)
+		b, err := ioutil.ReadAll(r)
+		c.Assert(err, IsNil)
+		c.Assert(r.Close(), IsNil)
+		c.Assert(string(b), Equals, osRelease)
+
+		r, err = index.Open("Command")
+		c.Assert(err, IsNil)
+		b, err = ioutil.ReadAll(r)
+		c.Assert(err, IsNil)
+		c.Assert(string(b), Equals, "Sun Apr  7 20:23:42 CEST 2019\n\n")
+
+		_, err = index.Open("/etc/passwd")
+		c.Assert(errors.Is(err, os.ErrNotExist), Equals, true)
+	}
+}
+
+func (is *indexSuite) TestIndexOpenWithoutSeparator(c *C) {
+	source := `
+#==[ Configuration File ]===========================#
+# /etc/short.conf
+one newline
+#==[ Configuration File ]===========================#
+# /etc/last.conf
+line1
+line2
+`
+	index, err := supportconfig.BuildIndex(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	for path, content := range map[string]string{
+		"/etc/short.conf": "one newline\n",
+		"/etc/last.conf":  "line1\nline2\n",
+	} {
+		r, err := index.Open(path)
+		c.Assert(err, IsNil)
+		b, err := ioutil.ReadAll(r)
+		c.Assert(err, IsNil)
+		c.Assert(string(b), Equals, content)
+	}
+}
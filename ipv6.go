@@ -0,0 +1,241 @@
+package supportconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IPv6Config has the IPv6 state of the network interfaces
+type IPv6Config struct {
+	// Interfaces are the interfaces found in the sysctls or with
+	// addresses, sorted by name
+	Interfaces []IPv6Interface
+}
+
+// IPv6Interface has the IPv6 sysctls and the addresses of an interface
+type IPv6Interface struct {
+	Name string
+
+	// Disabled is set by net.ipv6.conf.<name>.disable_ipv6
+	Disabled bool
+
+	// Forwarding is set by net.ipv6.conf.<name>.forwarding
+	Forwarding bool
+
+	// AcceptRA is net.ipv6.conf.<name>.accept_ra: 0 ignores router
+	// advertisements, 1 accepts them unless forwarding and 2 accepts
+	// them even when forwarding
+	AcceptRA int
+
+	// Autoconf says whether addresses are configured from the prefixes
+	// advertised
+	Autoconf bool
+
+	// TempAddr is net.ipv6.conf.<name>.use_tempaddr, for privacy
+	// extensions
+	TempAddr int
+
+	// Addresses are the IPv6 addresses, as listed by ip addr
+	Addresses []IPv6Address
+}
+
+// IPv6Address is an address of an interface, as listed by ip addr
+type IPv6Address struct {
+	// Address is the address, in CIDR notation
+	Address string
+
+	// Scope is the scope of the address, such as global or link
+	Scope string
+
+	// Flags are the flags of the address, such as dynamic, deprecated,
+	// tentative or dadfailed
+	Flags []string
+}
+
+// Has says whether the address has the given flag
+func (a IPv6Address) Has(flag string) bool {
+	return containsString(a.Flags, flag)
+}
+
+// ParseIPv6 reads the IPv6 sysctls, from sysctl -a and the files in
+// /proc/sys/net/ipv6, and the addresses listed by ip addr found in the
+// source
+func ParseIPv6(source io.Reader) (*IPv6Config, error) {
+	config := &IPv6Config{}
+	if err := Analyze(source, IPv6Analyzer(config)); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// IPv6Analyzer works as ParseIPv6, filling config
+func IPv6Analyzer(config *IPv6Config) Analyzer {
+	return func(p *Parser) func() error {
+		tree := newTreeOf("/proc/sys/net/ipv6/conf")
+		addresses := make(map[string][]IPv6Address)
+		p.HandleSection("Configuration File", tree.fileHandler)
+		p.HandleSection("Command", tree.sysctlHandler)
+		p.HandleSection("Command", func(section, afterline string) (io.WriteCloser, error) {
+			if !isIPAddr(strings.Fields(strings.TrimPrefix(afterline, "# "))) {
+				return nil, nil
+			}
+			return &treeWriter{done: func(body []byte) {
+				for name, found := range parseIPAddr(body) {
+					addresses[name] = found
+				}
+			}}, nil
+		})
+		return func() error {
+			names := tree.Children("/proc/sys/net/ipv6/conf")
+			for name := range addresses {
+				if !containsString(names, name) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if name == "all" || name == "default" || name == "lo" {
+					continue
+				}
+				// the defaults of the kernel hold for the sysctls not found
+				iface := IPv6Interface{Name: name, Addresses: addresses[name], AcceptRA: 1, Autoconf: true}
+				sysctl := func(key string) (int, bool) {
+					value, found := tree.Get(path.Join("/proc/sys/net/ipv6/conf", name, key))
+					if !found {
+						return 0, false
+					}
+					n, err := strconv.Atoi(value)
+					return n, err == nil
+				}
+				if n, found := sysctl("disable_ipv6"); found {
+					iface.Disabled = n != 0
+				}
+				if n, found := sysctl("forwarding"); found {
+					iface.Forwarding = n != 0
+				}
+				if n, found := sysctl("accept_ra"); found {
+					iface.AcceptRA = n
+				}
+				if n, found := sysctl("autoconf"); found {
+					iface.Autoconf = n != 0
+				}
+				if n, found := sysctl("use_tempaddr"); found {
+					iface.TempAddr = n
+				}
+				config.Interfaces = append(config.Interfaces, iface)
+			}
+			return nil
+		}
+	}
+}
+
+// ipArgOptions are the options of ip taking an argument
+var ipArgOptions = map[string]bool{
+	"-f": true, "-family": true, "-n": true, "-netns": true,
+	"-b": true, "-batch": true, "-rc": true, "-rcvbuf": true, "-l": true, "-loops": true,
+}
+
+// isIPAddr says whether a command is ip addr, in any of its spellings,
+// such as ip -o addr or ip -f inet6 address show
+func isIPAddr(fields []string) bool {
+	if len(fields) < 2 || path.Base(fields[0]) != "ip" {
+		return false
+	}
+	for i := 1; i < len(fields); i++ {
+		field := fields[i]
+		if !strings.HasPrefix(field, "-") {
+			return strings.HasPrefix("address", field)
+		}
+		if ipArgOptions[field] {
+			i++
+		}
+	}
+	return false
+}
+
+// parseIPAddr reads the IPv6 addresses of each interface in the output of
+// ip addr
+func parseIPAddr(body []byte) map[string][]IPv6Address {
+	addresses := make(map[string][]IPv6Address)
+	name := ""
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			// 2: eth0@if5: <BROADCAST,MULTICAST,UP> mtu 1500
+			// or, with ip -o, the whole address in a line:
+			// 2: eth0    inet6 fe80::1/64 scope link \       valid_lft forever
+			name = strings.TrimSuffix(fields[1], ":")
+			if idx := strings.Index(name, "@"); idx > 0 {
+				name = name[:idx]
+			}
+			fields = fields[2:]
+		}
+		if len(fields) < 2 || fields[0] != "inet6" || name == "" {
+			continue
+		}
+		address := IPv6Address{Address: fields[1]}
+		for i := 2; i < len(fields); i++ {
+			if fields[i] == `\` {
+				// the lifetimes follow
+				break
+			}
+			if fields[i] == "scope" && i+1 < len(fields) {
+				address.Scope = fields[i+1]
+				i++
+				continue
+			}
+			address.Flags = append(address.Flags, fields[i])
+		}
+		addresses[name] = append(addresses[name], address)
+	}
+	return addresses
+}
+
+// CheckIPv6 looks for the IPv6 settings known to cause intermittent
+// connectivity on dual-stack hosts: router advertisements ignored because
+// of forwarding, addresses that failed duplicate address detection and
+// interfaces left with deprecated global addresses only
+func CheckIPv6(config *IPv6Config) []Finding {
+	var findings []Finding
+	for _, iface := range config.Interfaces {
+		if iface.Disabled {
+			continue
+		}
+		if iface.Forwarding && iface.AcceptRA == 1 && iface.Autoconf {
+			name := path.Join("/proc/sys/net/ipv6/conf", iface.Name, "accept_ra")
+			findings = append(findings, Finding{Path: name, Value: "1",
+				Message: "accept_ra should be 2 on forwarding interfaces, or router advertisements are ignored"})
+		}
+		global, preferred := 0, 0
+		for _, address := range iface.Addresses {
+			if address.Has("dadfailed") {
+				findings = append(findings, Finding{Path: iface.Name, Value: address.Address,
+					Message: "address failed duplicate address detection, it is in use by another host"})
+				continue
+			}
+			if address.Scope != "global" {
+				continue
+			}
+			global++
+			if !address.Has("deprecated") {
+				preferred++
+			}
+		}
+		if global > 0 && preferred == 0 {
+			findings = append(findings, Finding{Path: iface.Name, Value: fmt.Sprintf("%d deprecated", global),
+				Message: "interface should have a preferred global address, are router advertisements still received?"})
+		}
+	}
+	return findings
+}
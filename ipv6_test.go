@@ -0,0 +1,121 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type ipv6Suite struct {
+}
+
+var _ = Suite(&ipv6Suite{})
+
+const sampleIPv6 = `#==[ Command ]======================================#
+# /sbin/sysctl -a
+net.ipv6.conf.all.forwarding = 1
+net.ipv6.conf.eth0.accept_ra = 1
+net.ipv6.conf.eth0.autoconf = 1
+net.ipv6.conf.eth0.forwarding = 1
+net.ipv6.conf.eth1.accept_ra = 2
+net.ipv6.conf.eth1.forwarding = 1
+net.ipv6.conf.eth2.disable_ipv6 = 1
+net.ipv6.conf.eth2.forwarding = 1
+net.ipv6.conf.lo.forwarding = 1
+
+#==[ Command ]======================================#
+# /sbin/ip addr
+1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN group default qlen 1000
+    link/loopback 00:00:00:00:00:00 brd 00:00:00:00:00:00
+    inet6 ::1/128 scope host
+       valid_lft forever preferred_lft forever
+2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc fq_codel state UP group default qlen 1000
+    inet 192.168.0.10/24 brd 192.168.0.255 scope global eth0
+    inet6 2001:db8::10/64 scope global dynamic deprecated mngtmpaddr
+       valid_lft 3000sec preferred_lft 0sec
+    inet6 fe80::10/64 scope link
+       valid_lft forever preferred_lft forever
+3: eth1@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UP group default
+    inet6 2001:db8:1::1/64 scope global
+       valid_lft forever preferred_lft forever
+    inet6 fe80::1/64 scope link dadfailed tentative
+       valid_lft forever preferred_lft forever
+
+`
+
+func (is *ipv6Suite) TestParseIPv6(c *C) {
+	config, err := supportconfig.ParseIPv6(strings.NewReader(sampleIPv6))
+	c.Assert(err, IsNil)
+	c.Assert(config.Interfaces, HasLen, 3)
+	eth0 := config.Interfaces[0]
+	c.Assert(eth0.Name, Equals, "eth0")
+	c.Assert(eth0.Forwarding, Equals, true)
+	c.Assert(eth0.AcceptRA, Equals, 1)
+	c.Assert(eth0.Addresses, DeepEquals, []supportconfig.IPv6Address{
+		{Address: "2001:db8::10/64", Scope: "global", Flags: []string{"dynamic", "deprecated", "mngtmpaddr"}},
+		{Address: "fe80::10/64", Scope: "link"},
+	})
+	eth1 := config.Interfaces[1]
+	c.Assert(eth1.Name, Equals, "eth1")
+	c.Assert(eth1.AcceptRA, Equals, 2)
+	c.Assert(eth1.Addresses[1].Has("dadfailed"), Equals, true)
+	c.Assert(config.Interfaces[2].Disabled, Equals, true)
+}
+
+// sampleIPv6Sysctl has the sysctls of sampleIPv6, to be followed by the
+// addresses in the other forms of ip addr
+var sampleIPv6Sysctl = sampleIPv6[:strings.Index(sampleIPv6, "\n#==[")+1]
+
+const sampleIPAddrOneline = `#==[ Command ]======================================#
+# /sbin/ip -o addr
+1: lo    inet6 ::1/128 scope host \       valid_lft forever preferred_lft forever
+2: eth0    inet 192.168.0.10/24 brd 192.168.0.255 scope global eth0\       valid_lft forever preferred_lft forever
+2: eth0    inet6 2001:db8::10/64 scope global dynamic deprecated mngtmpaddr \       valid_lft 3000sec preferred_lft 0sec
+2: eth0    inet6 fe80::10/64 scope link \       valid_lft forever preferred_lft forever
+3: eth1    inet6 2001:db8:1::1/64 scope global \       valid_lft forever preferred_lft forever
+3: eth1    inet6 fe80::1/64 scope link dadfailed tentative \       valid_lft forever preferred_lft forever
+
+`
+
+const sampleIPAddrFamily = `#==[ Command ]======================================#
+# /sbin/ip -f inet6 addr show
+1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 state UNKNOWN qlen 1000
+    inet6 ::1/128 scope host
+       valid_lft forever preferred_lft forever
+2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 state UP qlen 1000
+    inet6 2001:db8::10/64 scope global dynamic deprecated mngtmpaddr
+       valid_lft 3000sec preferred_lft 0sec
+    inet6 fe80::10/64 scope link
+       valid_lft forever preferred_lft forever
+3: eth1@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 state UP
+    inet6 2001:db8:1::1/64 scope global
+       valid_lft forever preferred_lft forever
+    inet6 fe80::1/64 scope link dadfailed tentative
+       valid_lft forever preferred_lft forever
+
+`
+
+func (is *ipv6Suite) TestParseIPv6AddrForms(c *C) {
+	expected, err := supportconfig.ParseIPv6(strings.NewReader(sampleIPv6))
+	c.Assert(err, IsNil)
+	for _, addr := range []string{sampleIPAddrOneline, sampleIPAddrFamily} {
+		config, err := supportconfig.ParseIPv6(strings.NewReader(sampleIPv6Sysctl + addr))
+		c.Assert(err, IsNil)
+		c.Assert(config.Interfaces, DeepEquals, expected.Interfaces, Commentf("%s", addr))
+	}
+}
+
+func (is *ipv6Suite) TestCheckIPv6(c *C) {
+	config, err := supportconfig.ParseIPv6(strings.NewReader(sampleIPv6))
+	c.Assert(err, IsNil)
+	var findings []string
+	for _, finding := range supportconfig.CheckIPv6(config) {
+		findings = append(findings, finding.Path+" "+finding.Value)
+	}
+	c.Assert(findings, DeepEquals, []string{
+		"/proc/sys/net/ipv6/conf/eth0/accept_ra 1",
+		"eth0 1 deprecated",
+		"eth1 fe80::1/64",
+	})
+}
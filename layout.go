@@ -0,0 +1,97 @@
+package supportconfig
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RootSubtree is the directory used by MountLayout for files living in
+// the root filesystem
+const RootSubtree = "rootfs"
+
+// ParseMounts reads mount points from the contents of /etc/fstab,
+// /proc/mounts or the output of the mount command, in any of these
+// formats. Entries that are not absolute paths (swap, none) are ignored.
+func ParseMounts(source io.Reader) ([]string, error) {
+	var mounts []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(source)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		mount := fields[1]
+		if mount == "on" && len(fields) > 2 {
+			// mount output: "device on /path type fstype (options)"
+			mount = fields[2]
+		}
+		mount = unescapeMount(mount)
+		if !strings.HasPrefix(mount, "/") {
+			continue
+		}
+		mount = path.Clean(mount)
+		if !seen[mount] {
+			seen[mount] = true
+			mounts = append(mounts, mount)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// unescapeMount decodes the octal escapes (\040 for space) used by fstab
+// and /proc/mounts
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// MountLayout returns a PathHandlerFunc that places every file under a
+// subtree named after the mount point holding it, so that /var/log/messages
+// with /var mounted separately is written to var/log/messages and
+// /etc/fstab to rootfs/etc/fstab. Nested mount points have their slashes
+// replaced by dashes, as in var-lib-docker/.
+func MountLayout(mounts []string) PathHandlerFunc {
+	sorted := make([]string, 0, len(mounts))
+	for _, mount := range mounts {
+		sorted = append(sorted, path.Clean(mount))
+	}
+	// longest mount points first, so the innermost one wins
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	return func(p string) (string, error) {
+		for _, mount := range sorted {
+			if mount == "/" {
+				break
+			}
+			if p == mount || strings.HasPrefix(p, mount+"/") {
+				subtree := strings.Replace(strings.Trim(mount, "/"), "/", "-", -1)
+				return path.Join(subtree, strings.TrimPrefix(p, mount)), nil
+			}
+		}
+		return path.Join(RootSubtree, p), nil
+	}
+}
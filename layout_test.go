@@ -0,0 +1,77 @@
+package supportconfig_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type layoutSuite struct {
+}
+
+var _ = Suite(&layoutSuite{})
+
+const sampleFstab = `# /etc/fstab
+UUID=1234 /          btrfs defaults 0 0
+UUID=5678 /var       xfs   defaults 0 0
+UUID=9abc swap       swap  defaults 0 0
+/dev/sdb1 /srv/my\040data ext4 noatime 0 2
+`
+
+const sampleMountOutput = `/dev/sda2 on / type btrfs (rw,relatime)
+/dev/sda3 on /var type xfs (rw,relatime)
+/dev/sdc1 on /var/lib/docker type xfs (rw,relatime)
+proc on /proc type proc (rw,nosuid,nodev,noexec,relatime)
+`
+
+func (ls *layoutSuite) TestParseMountsFstab(c *C) {
+	mounts, err := supportconfig.ParseMounts(strings.NewReader(sampleFstab))
+	c.Assert(err, IsNil)
+	c.Assert(mounts, DeepEquals, []string{"/", "/var", "/srv/my data"})
+}
+
+func (ls *layoutSuite) TestParseMountsCommand(c *C) {
+	mounts, err := supportconfig.ParseMounts(strings.NewReader(sampleMountOutput))
+	c.Assert(err, IsNil)
+	c.Assert(mounts, DeepEquals, []string{"/", "/var", "/var/lib/docker", "/proc"})
+}
+
+func (ls *layoutSuite) TestMountLayout(c *C) {
+	mounts, err := supportconfig.ParseMounts(strings.NewReader(sampleMountOutput))
+	c.Assert(err, IsNil)
+	layout := supportconfig.MountLayout(mounts)
+
+	for path, expected := range map[string]string{
+		"/etc/fstab":                   "rootfs/etc/fstab",
+		"/var/log/messages":            "var/log/messages",
+		"/variable":                    "rootfs/variable",
+		"/var/lib/docker/config.json":  "var-lib-docker/config.json",
+		"/proc/cmdline":                "proc/cmdline",
+		"/var":                         "var",
+		"/etc/sysconfig/network/ifcfg": "rootfs/etc/sysconfig/network/ifcfg",
+	} {
+		dest, err := layout(path)
+		c.Assert(err, IsNil)
+		c.Assert(dest, Equals, expected, Commentf("path %s", path))
+	}
+}
+
+func (ls *layoutSuite) TestSplitterMountLayout(c *C) {
+	base := c.MkDir()
+	layout := supportconfig.MountLayout([]string{"/", "/var"})
+	config := supportconfig.Config{Base: base, PathHandler: layout}
+	splitter := &supportconfig.Splitter{Config: config}
+
+	err := splitter.Split(strings.NewReader(sampleMultipleGroups + logEntryWithNote))
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(filepath.Join(base, "rootfs/etc/SuSE-release"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, etcRelease)
+	b, err = ioutil.ReadFile(filepath.Join(base, "var/log/nodes/logname.log"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, logEntry)
+}
@@ -0,0 +1,459 @@
+package supportconfig
+
+import (
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Network stacks whose configuration is read by ParseNetwork
+const (
+	Wicked         = "wicked"
+	NetworkManager = "NetworkManager"
+)
+
+// Methods of configuring the addresses of an interface
+const (
+	MethodDHCP     = "dhcp"
+	MethodStatic   = "static"
+	MethodDisabled = "disabled"
+)
+
+// NetworkConfig has the configuration of the network interfaces, read from
+// the profiles of either wicked or NetworkManager
+type NetworkConfig struct {
+	// Stacks are the network stacks whose profiles were found
+	Stacks []string
+
+	// Interfaces are the interfaces configured, sorted by name
+	Interfaces []InterfaceConfig
+
+	// Gateway is the default gateway not bound to an interface
+	Gateway string
+
+	// OVS are the Open vSwitch bridges, as shown by ovs-vsctl show
+	OVS []OVSBridge
+}
+
+// InterfaceConfig is the configuration of a network interface, the same
+// whatever the network stack
+type InterfaceConfig struct {
+	// Name is the name of the interface, such as eth0
+	Name string
+
+	// Stack is the network stack configuring the interface, and Profile
+	// the path of its profile
+	Stack   string
+	Profile string
+
+	// Type is the type of the interface: ethernet, vlan, bridge, bond
+	// or ovs-bridge
+	Type string
+
+	// VLAN is the VLAN ID of vlan interfaces, and Parent the interface
+	// they are on
+	VLAN   int
+	Parent string
+
+	// Ports are the interfaces enslaved by bridges and bonds, and
+	// Master the bridge or bond an interface is a port of
+	Ports  []string
+	Master string
+
+	// Method and Method6 are how the IPv4 and IPv6 addresses are
+	// configured: dhcp, static, disabled, or empty when not set
+	Method  string
+	Method6 string
+
+	// Addresses are the static addresses, in CIDR notation
+	Addresses []string
+
+	// Gateway is the default gateway of the interface
+	Gateway string
+
+	// MTU is the MTU set, or zero
+	MTU int
+
+	// OnBoot says whether the interface is brought up on boot
+	OnBoot bool
+}
+
+// Types of the interfaces
+const (
+	TypeEthernet  = "ethernet"
+	TypeVLAN      = "vlan"
+	TypeBridge    = "bridge"
+	TypeBond      = "bond"
+	TypeOVSBridge = "ovs-bridge"
+)
+
+// OVSBridge is a bridge of Open vSwitch
+type OVSBridge struct {
+	Name  string
+	Ports []OVSPort
+}
+
+// OVSPort is a port of a bridge of Open vSwitch
+type OVSPort struct {
+	Name string
+
+	// Type is the type of its interface, such as internal, patch or
+	// vxlan, or empty for system interfaces
+	Type string
+
+	// Tag is the VLAN tag of access ports, or zero
+	Tag int
+
+	// Options are the options of its interface, such as the peer of
+	// patch ports
+	Options string
+}
+
+// Interface returns the configuration of the named interface
+func (n *NetworkConfig) Interface(name string) (InterfaceConfig, bool) {
+	for _, iface := range n.Interfaces {
+		if iface.Name == name {
+			return iface, true
+		}
+	}
+	return InterfaceConfig{}, false
+}
+
+// ParseNetwork reads the ifcfg and route files of wicked, in
+// /etc/sysconfig/network, the connection profiles of NetworkManager and
+// the output of ovs-vsctl show found in the source
+func ParseNetwork(source io.Reader) (*NetworkConfig, error) {
+	network := &NetworkConfig{}
+	if err := Analyze(source, NetworkAnalyzer(network)); err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+// NetworkAnalyzer works as ParseNetwork, filling network
+func NetworkAnalyzer(network *NetworkConfig) Analyzer {
+	return func(p *Parser) func() error {
+		gateways := make(map[string]string)
+		p.HandleSection("Command", func(section, afterline string) (io.WriteCloser, error) {
+			fields := strings.Fields(strings.TrimPrefix(afterline, "# "))
+			if len(fields) < 2 || path.Base(fields[0]) != "ovs-vsctl" || fields[1] != "show" {
+				return nil, nil
+			}
+			return &treeWriter{done: func(body []byte) {
+				network.OVS = parseOVSShow(string(body))
+			}}, nil
+		})
+		p.HandleSection("Configuration File", func(section, afterline string) (io.WriteCloser, error) {
+			name, err := afterlineToPath(strings.TrimPrefix(afterline, "# "))
+			if err != nil {
+				return nil, err
+			}
+			name = path.Clean(name)
+			dir, base := path.Split(name)
+			switch {
+			case dir == "/etc/sysconfig/network/" && strings.HasPrefix(base, "ifcfg-"):
+				iface := strings.TrimPrefix(base, "ifcfg-")
+				if iface == "lo" {
+					return nil, nil
+				}
+				return &treeWriter{done: func(body []byte) {
+					network.add(parseIfcfg(iface, name, string(body)))
+				}}, nil
+			case name == "/etc/sysconfig/network/routes" || (dir == "/etc/sysconfig/network/" && strings.HasPrefix(base, "ifroute-")):
+				iface := strings.TrimPrefix(base, "ifroute-")
+				if base == "routes" {
+					iface = ""
+				}
+				return &treeWriter{done: func(body []byte) {
+					setRoutes(gateways, iface, string(body))
+				}}, nil
+			case dir == "/etc/NetworkManager/system-connections/":
+				return &treeWriter{done: func(body []byte) {
+					if iface, found := parseNMConnection(name, string(body)); found {
+						network.add(iface)
+					}
+				}}, nil
+			}
+			return nil, nil
+		})
+		return func() error {
+			network.Gateway = gateways[""]
+			for i := range network.Interfaces {
+				iface := &network.Interfaces[i]
+				if gateway, found := gateways[iface.Name]; found && iface.Gateway == "" {
+					iface.Gateway = gateway
+				}
+			}
+			sort.SliceStable(network.Interfaces, func(i, j int) bool {
+				return network.Interfaces[i].Name < network.Interfaces[j].Name
+			})
+			network.setPorts()
+			return nil
+		}
+	}
+}
+
+// setPorts fills the ports of bridges and bonds from the masters of their
+// ports, as set by NetworkManager, and the other way around, as set by
+// wicked
+func (n *NetworkConfig) setPorts() {
+	index := make(map[string]int)
+	for i, iface := range n.Interfaces {
+		index[iface.Name] = i
+	}
+	for i := range n.Interfaces {
+		iface := &n.Interfaces[i]
+		for _, port := range iface.Ports {
+			if j, found := index[port]; found && n.Interfaces[j].Master == "" {
+				n.Interfaces[j].Master = iface.Name
+			}
+		}
+	}
+	for _, iface := range n.Interfaces {
+		if j, found := index[iface.Master]; found && !containsString(n.Interfaces[j].Ports, iface.Name) {
+			n.Interfaces[j].Ports = append(n.Interfaces[j].Ports, iface.Name)
+		}
+	}
+}
+
+func (n *NetworkConfig) add(iface InterfaceConfig) {
+	if !containsString(n.Stacks, iface.Stack) {
+		n.Stacks = append(n.Stacks, iface.Stack)
+	}
+	n.Interfaces = append(n.Interfaces, iface)
+}
+
+// shellValue removes the quotes of a value of a sysconfig file
+func shellValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseIfcfg reads an ifcfg file of wicked, with variables such as
+// BOOTPROTO='dhcp' and IPADDR='192.168.0.10/24'
+func parseIfcfg(name, profile, content string) InterfaceConfig {
+	iface := InterfaceConfig{Name: name, Stack: Wicked, Profile: profile}
+	vars := make(map[string]string)
+	var suffixes []string
+	for _, line := range configLines(content) {
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		vars[key] = shellValue(line[idx+1:])
+		if strings.HasPrefix(key, "IPADDR") {
+			suffixes = append(suffixes, strings.TrimPrefix(key, "IPADDR"))
+		}
+	}
+
+	switch vars["BOOTPROTO"] {
+	case "dhcp":
+		iface.Method, iface.Method6 = MethodDHCP, MethodDHCP
+	case "dhcp4":
+		iface.Method = MethodDHCP
+	case "dhcp6":
+		iface.Method6 = MethodDHCP
+	case "static":
+		iface.Method = MethodStatic
+	case "none":
+		iface.Method, iface.Method6 = MethodDisabled, MethodDisabled
+	}
+	for _, suffix := range suffixes {
+		address := vars["IPADDR"+suffix]
+		if address == "" {
+			continue
+		}
+		if !strings.Contains(address, "/") {
+			if prefix := vars["PREFIXLEN"+suffix]; prefix != "" {
+				address += "/" + prefix
+			} else if mask := net.ParseIP(vars["NETMASK"+suffix]).To4(); mask != nil {
+				ones, _ := net.IPMask(mask).Size()
+				address += "/" + strconv.Itoa(ones)
+			}
+		}
+		if strings.Contains(address, ":") && iface.Method6 == "" {
+			iface.Method6 = MethodStatic
+		}
+		iface.Addresses = append(iface.Addresses, address)
+	}
+	iface.MTU, _ = strconv.Atoi(vars["MTU"])
+
+	iface.Type = TypeEthernet
+	switch {
+	case vars["BRIDGE"] == "yes":
+		iface.Type = TypeBridge
+		iface.Ports = strings.Fields(vars["BRIDGE_PORTS"])
+	case vars["BONDING_MASTER"] == "yes":
+		iface.Type = TypeBond
+		for key, value := range vars {
+			if strings.HasPrefix(key, "BONDING_SLAVE") && value != "" {
+				iface.Ports = append(iface.Ports, value)
+			}
+		}
+		sort.Strings(iface.Ports)
+	case vars["OVS_BRIDGE"] == "yes":
+		iface.Type = TypeOVSBridge
+		for key, value := range vars {
+			if strings.HasPrefix(key, "OVS_BRIDGE_PORT_DEVICE") && value != "" {
+				iface.Ports = append(iface.Ports, value)
+			}
+		}
+		sort.Strings(iface.Ports)
+	case vars["ETHERDEVICE"] != "":
+		iface.Type = TypeVLAN
+		iface.Parent = vars["ETHERDEVICE"]
+		iface.VLAN, _ = strconv.Atoi(vars["VLAN_ID"])
+		if iface.VLAN == 0 {
+			// vlan10 and eth0.10 have the ID in their names
+			iface.VLAN, _ = strconv.Atoi(name[strings.LastIndexAny(name, ".n")+1:])
+		}
+	}
+	switch vars["STARTMODE"] {
+	case "auto", "onboot", "nfsroot", "hotplug":
+		iface.OnBoot = true
+	}
+	return iface
+}
+
+// setRoutes reads the default routes of a routes or ifroute file, with
+// lines such as "default 192.168.0.1 - eth0"
+func setRoutes(gateways map[string]string, iface, content string) {
+	for _, line := range configLines(content) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || (fields[0] != "default" && fields[0] != "0.0.0.0/0") {
+			continue
+		}
+		name := iface
+		if len(fields) >= 4 && fields[3] != "-" {
+			name = fields[3]
+		}
+		gateways[name] = fields[1]
+	}
+}
+
+// parseNMConnection reads a keyfile connection profile of NetworkManager,
+// returning false for connections that aren't bound to an interface
+func parseNMConnection(profile, content string) (InterfaceConfig, bool) {
+	iface := InterfaceConfig{Stack: NetworkManager, Profile: profile, OnBoot: true}
+	group := ""
+	for _, line := range configLines(content) {
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = line[1 : len(line)-1]
+			continue
+		}
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:])
+		switch {
+		case group == "connection" && key == "interface-name":
+			iface.Name = value
+		case group == "connection" && key == "autoconnect":
+			iface.OnBoot = value != "false"
+		case group == "connection" && key == "type":
+			iface.Type = nmType(value)
+		case group == "connection" && (key == "master" || key == "controller"):
+			iface.Master = value
+		case group == "vlan" && key == "id":
+			iface.VLAN, _ = strconv.Atoi(value)
+		case group == "vlan" && key == "parent":
+			iface.Parent = value
+		case (group == "ethernet" || group == "802-3-ethernet") && key == "mtu":
+			iface.MTU, _ = strconv.Atoi(value)
+		case group == "ipv4" && key == "method":
+			iface.Method = nmMethod(value)
+		case group == "ipv6" && key == "method":
+			iface.Method6 = nmMethod(value)
+		case (group == "ipv4" || group == "ipv6") && key == "gateway":
+			if group == "ipv4" || iface.Gateway == "" {
+				iface.Gateway = value
+			}
+		case (group == "ipv4" || group == "ipv6") && strings.HasPrefix(key, "address"):
+			// address1=192.168.0.10/24,192.168.0.1 has the gateway
+			// after the comma
+			for _, address := range strings.Split(value, ";") {
+				fields := strings.SplitN(address, ",", 2)
+				if fields[0] == "" {
+					continue
+				}
+				iface.Addresses = append(iface.Addresses, fields[0])
+				if len(fields) == 2 && iface.Gateway == "" {
+					iface.Gateway = fields[1]
+				}
+			}
+		}
+	}
+	return iface, iface.Name != ""
+}
+
+// nmType returns the type of a connection of NetworkManager as the type
+// of its interface
+func nmType(connection string) string {
+	switch connection {
+	case "802-3-ethernet":
+		return TypeEthernet
+	case "ovs-bridge":
+		return TypeOVSBridge
+	}
+	return connection
+}
+
+// nmMethod returns the method of NetworkManager as the one of wicked
+func nmMethod(method string) string {
+	switch method {
+	case "auto", "dhcp":
+		return MethodDHCP
+	case "manual":
+		return MethodStatic
+	case "disabled", "ignore":
+		return MethodDisabled
+	}
+	return method
+}
+
+// parseOVSShow reads the bridges, ports and interfaces in the output of
+// ovs-vsctl show, told apart by their indentation
+func parseOVSShow(content string) []OVSBridge {
+	var bridges []OVSBridge
+	var port *OVSPort
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) < 2 {
+			continue
+		}
+		value := strings.Trim(fields[1], `"`)
+		switch fields[0] {
+		case "Bridge":
+			bridges = append(bridges, OVSBridge{Name: value})
+			port = nil
+		case "Port":
+			if len(bridges) == 0 {
+				continue
+			}
+			bridge := &bridges[len(bridges)-1]
+			bridge.Ports = append(bridge.Ports, OVSPort{Name: value})
+			port = &bridge.Ports[len(bridge.Ports)-1]
+		case "tag:":
+			if port != nil {
+				port.Tag, _ = strconv.Atoi(value)
+			}
+		case "type:":
+			if port != nil {
+				port.Type = value
+			}
+		case "options:":
+			if port != nil {
+				port.Options = value
+			}
+		}
+	}
+	return bridges
+}
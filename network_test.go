@@ -0,0 +1,224 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type networkSuite struct {
+}
+
+var _ = Suite(&networkSuite{})
+
+const sampleWicked = `#==[ Configuration File ]===========================#
+# /etc/sysconfig/network/ifcfg-eth0
+BOOTPROTO='static'
+STARTMODE='auto'
+IPADDR='192.168.0.10'
+NETMASK='255.255.255.0'
+IPADDR_1='2001:db8::10/64'
+MTU='9000'
+
+#==[ Configuration File ]===========================#
+# /etc/sysconfig/network/ifcfg-eth1
+BOOTPROTO="dhcp4"
+STARTMODE="manual"
+
+#==[ Configuration File ]===========================#
+# /etc/sysconfig/network/ifcfg-lo
+IPADDR=127.0.0.1/8
+
+#==[ Configuration File ]===========================#
+# /etc/sysconfig/network/routes
+default 192.168.0.1 - eth0
+default 10.0.0.1 - -
+
+`
+
+const sampleNetworkManager = `#==[ Configuration File ]===========================#
+# /etc/NetworkManager/system-connections/Wired.nmconnection
+[connection]
+id=Wired
+type=ethernet
+interface-name=enp1s0
+
+[ethernet]
+mtu=1400
+
+[ipv4]
+address1=10.1.0.5/16,10.1.0.1
+method=manual
+
+[ipv6]
+method=auto
+
+#==[ Configuration File ]===========================#
+# /etc/NetworkManager/system-connections/vpn.nmconnection
+[connection]
+id=vpn
+type=vpn
+autoconnect=false
+
+`
+
+func (ns *networkSuite) TestParseWicked(c *C) {
+	network, err := supportconfig.ParseNetwork(strings.NewReader(sampleWicked))
+	c.Assert(err, IsNil)
+	c.Assert(network.Stacks, DeepEquals, []string{supportconfig.Wicked})
+	c.Assert(network.Gateway, Equals, "10.0.0.1")
+	c.Assert(network.Interfaces, DeepEquals, []supportconfig.InterfaceConfig{
+		{
+			Name:      "eth0",
+			Stack:     supportconfig.Wicked,
+			Profile:   "/etc/sysconfig/network/ifcfg-eth0",
+			Type:      supportconfig.TypeEthernet,
+			Method:    supportconfig.MethodStatic,
+			Method6:   supportconfig.MethodStatic,
+			Addresses: []string{"192.168.0.10/24", "2001:db8::10/64"},
+			Gateway:   "192.168.0.1",
+			MTU:       9000,
+			OnBoot:    true,
+		},
+		{
+			Name:    "eth1",
+			Stack:   supportconfig.Wicked,
+			Profile: "/etc/sysconfig/network/ifcfg-eth1",
+			Type:    supportconfig.TypeEthernet,
+			Method:  supportconfig.MethodDHCP,
+		},
+	})
+}
+
+func (ns *networkSuite) TestParseNetworkManager(c *C) {
+	network, err := supportconfig.ParseNetwork(strings.NewReader(sampleNetworkManager))
+	c.Assert(err, IsNil)
+	c.Assert(network.Stacks, DeepEquals, []string{supportconfig.NetworkManager})
+	iface, found := network.Interface("enp1s0")
+	c.Assert(found, Equals, true)
+	c.Assert(iface, DeepEquals, supportconfig.InterfaceConfig{
+		Name:      "enp1s0",
+		Stack:     supportconfig.NetworkManager,
+		Profile:   "/etc/NetworkManager/system-connections/Wired.nmconnection",
+		Type:      supportconfig.TypeEthernet,
+		Method:    supportconfig.MethodStatic,
+		Method6:   supportconfig.MethodDHCP,
+		Addresses: []string{"10.1.0.5/16"},
+		Gateway:   "10.1.0.1",
+		MTU:       1400,
+		OnBoot:    true,
+	})
+	c.Assert(network.Interfaces, HasLen, 1)
+}
+
+func (ns *networkSuite) TestParseNetworkBothStacks(c *C) {
+	network, err := supportconfig.ParseNetwork(strings.NewReader(sampleNetworkManager + sampleWicked))
+	c.Assert(err, IsNil)
+	c.Assert(network.Stacks, DeepEquals, []string{supportconfig.NetworkManager, supportconfig.Wicked})
+	var names []string
+	for _, iface := range network.Interfaces {
+		names = append(names, iface.Name)
+	}
+	c.Assert(names, DeepEquals, []string{"enp1s0", "eth0", "eth1"})
+}
+
+const sampleVirtualNetwork = `#==[ Configuration File ]===========================#
+# /etc/sysconfig/network/ifcfg-br0
+BOOTPROTO='dhcp'
+STARTMODE='auto'
+BRIDGE='yes'
+BRIDGE_PORTS='eth0'
+
+#==[ Configuration File ]===========================#
+# /etc/sysconfig/network/ifcfg-vlan20
+STARTMODE='auto'
+ETHERDEVICE='eth1'
+
+#==[ Configuration File ]===========================#
+# /etc/NetworkManager/system-connections/bond0.nmconnection
+[connection]
+id=bond0
+type=bond
+interface-name=bond0
+
+#==[ Configuration File ]===========================#
+# /etc/NetworkManager/system-connections/eth2.nmconnection
+[connection]
+id=eth2
+type=802-3-ethernet
+interface-name=eth2
+master=bond0
+slave-type=bond
+
+[802-3-ethernet]
+mtu=9000
+
+#==[ Configuration File ]===========================#
+# /etc/NetworkManager/system-connections/vlan30.nmconnection
+[connection]
+id=vlan30
+type=vlan
+interface-name=bond0.30
+
+[vlan]
+id=30
+parent=bond0
+
+#==[ Command ]======================================#
+# /usr/bin/ovs-vsctl show
+0b1c2d3e-0000-4000-8000-000000000000
+    Bridge br-int
+        fail_mode: secure
+        Port br-int
+            Interface br-int
+                type: internal
+        Port "vm-port"
+            tag: 10
+            Interface "vm-port"
+        Port patch-ex
+            Interface patch-ex
+                type: patch
+                options: {peer=patch-int}
+    Bridge br-ex
+        Port eth3
+            Interface eth3
+    ovs_version: "2.14.2"
+
+`
+
+func (ns *networkSuite) TestParseVirtualNetwork(c *C) {
+	network, err := supportconfig.ParseNetwork(strings.NewReader(sampleWicked + sampleVirtualNetwork))
+	c.Assert(err, IsNil)
+
+	br0, _ := network.Interface("br0")
+	c.Assert(br0.Type, Equals, supportconfig.TypeBridge)
+	c.Assert(br0.Ports, DeepEquals, []string{"eth0"})
+	eth0, _ := network.Interface("eth0")
+	c.Assert(eth0.Master, Equals, "br0")
+
+	vlan20, _ := network.Interface("vlan20")
+	c.Assert(vlan20.Type, Equals, supportconfig.TypeVLAN)
+	c.Assert(vlan20.VLAN, Equals, 20)
+	c.Assert(vlan20.Parent, Equals, "eth1")
+
+	bond0, _ := network.Interface("bond0")
+	c.Assert(bond0.Type, Equals, supportconfig.TypeBond)
+	c.Assert(bond0.Ports, DeepEquals, []string{"eth2"})
+	eth2, _ := network.Interface("eth2")
+	c.Assert(eth2.Type, Equals, supportconfig.TypeEthernet)
+	c.Assert(eth2.MTU, Equals, 9000)
+
+	vlan30, _ := network.Interface("bond0.30")
+	c.Assert(vlan30.VLAN, Equals, 30)
+	c.Assert(vlan30.Parent, Equals, "bond0")
+
+	c.Assert(network.OVS, DeepEquals, []supportconfig.OVSBridge{
+		{Name: "br-int", Ports: []supportconfig.OVSPort{
+			{Name: "br-int", Type: "internal"},
+			{Name: "vm-port", Tag: 10},
+			{Name: "patch-ex", Type: "patch", Options: "{peer=patch-int}"},
+		}},
+		{Name: "br-ex", Ports: []supportconfig.OVSPort{{Name: "eth3"}}},
+	})
+}
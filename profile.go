@@ -0,0 +1,214 @@
+package supportconfig
+
+import (
+	"path"
+	"sort"
+	"sync"
+)
+
+// Profile selects a subset of the files embedded in a supportconfig, so
+// that only the ones relevant for a given investigation are extracted
+type Profile struct {
+	// Name identifies the profile
+	Name string
+
+	// Paths are shell patterns, with the syntax of path.Match, matched
+	// against the paths as found in the source
+	Paths []string
+
+	// Sources are patterns matched against the names of the text files
+	// of a supportconfig archive, such as network.txt. No patterns
+	// means all of them.
+	Sources []string
+
+	// Memory has the virtual memory settings recommended for the
+	// systems the profile is about, checked by CheckMemory
+	Memory *MemoryExpectations
+}
+
+// TriageProfile has the small set of files looked at first in most
+// cases, so that a huge archive can be triaged in seconds
+var TriageProfile = &Profile{
+	Name:    "triage",
+	Sources: []string{"basic-environment.txt", "boot.txt", "fs-diskio.txt", "messages.txt"},
+	Paths: []string{
+		"/etc/os-release",
+		"/etc/SuSE-release",
+		"/etc/fstab",
+		"/proc/mounts",
+		"/proc/cmdline",
+		"/var/log/messages",
+		"/var/log/warn",
+		"/var/log/boot.msg",
+	},
+}
+
+// KernelProfile has the files about the kernel, its modules and boot
+var KernelProfile = &Profile{
+	Name:    "kernel",
+	Sources: []string{"boot.txt", "modules.txt", "proc.txt", "crash.txt"},
+	Paths: []string{
+		"/proc/cmdline",
+		"/etc/default/grub",
+		"/boot/grub2/grub.cfg",
+		"/etc/sysctl.conf",
+		"/etc/sysctl.d/*",
+		"/etc/modprobe.d/*",
+		"/etc/sysconfig/kdump",
+		"/var/log/boot.msg",
+		"/var/log/messages",
+	},
+}
+
+// NetworkProfile has the network configuration files
+var NetworkProfile = &Profile{
+	Name:    "network",
+	Sources: []string{"network.txt", "dns.txt", "dhcp.txt"},
+	Paths: []string{
+		"/etc/hosts",
+		"/etc/resolv.conf",
+		"/etc/nsswitch.conf",
+		"/etc/sysconfig/network/*",
+		"/etc/wicked/*",
+		"/etc/NetworkManager/*",
+		"/etc/NetworkManager/system-connections/*",
+	},
+}
+
+// StorageProfile has the files about disks, filesystems and multipath
+var StorageProfile = &Profile{
+	Name:    "storage",
+	Sources: []string{"fs-*.txt", "lvm.txt", "mpio.txt"},
+	Paths: []string{
+		"/etc/fstab",
+		"/proc/mounts",
+		"/etc/multipath.conf",
+		"/etc/lvm/lvm.conf",
+		"/etc/mdadm.conf",
+		"/etc/iscsi/*",
+	},
+}
+
+// SAPProfile has the tuning files usually reviewed on SAP and database
+// hosts
+var SAPProfile = &Profile{
+	Name:    "sap",
+	Sources: []string{"proc.txt", "memory.txt", "sysconfig.txt"},
+	Paths: []string{
+		"/etc/sysctl.conf",
+		"/etc/sysctl.d/*",
+		"/etc/security/limits.conf",
+		"/etc/security/limits.d/*",
+		"/etc/sysconfig/saptune",
+		"/etc/saptune/*",
+		"/etc/tuned/*",
+		"/proc/meminfo",
+	},
+	Memory: &MemoryExpectations{
+		THP:        []string{"never"},
+		Swappiness: &Range{Min: 0, Max: 10},
+	},
+}
+
+// KubernetesProfile has the configuration of the container runtimes and
+// of kubernetes itself
+var KubernetesProfile = &Profile{
+	Name: "kubernetes",
+	Paths: []string{
+		"/etc/kubernetes/*",
+		"/etc/kubernetes/*/*",
+		"/etc/containerd/*",
+		"/etc/crio/*",
+		"/etc/docker/daemon.json",
+		"/etc/sysctl.d/*",
+	},
+}
+
+var (
+	profilesMutex sync.RWMutex
+	profiles      = make(map[string]*Profile)
+)
+
+func init() {
+	for _, p := range []*Profile{TriageProfile, KernelProfile, NetworkProfile,
+		StorageProfile, SAPProfile, KubernetesProfile} {
+		RegisterProfile(p)
+	}
+}
+
+// RegisterProfile makes a profile available by its name, replacing any
+// profile previously registered with the same name
+func RegisterProfile(p *Profile) {
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+	profiles[p.Name] = p
+}
+
+// LookupProfile returns the profile registered with the given name
+func LookupProfile(name string) (*Profile, bool) {
+	profilesMutex.RLock()
+	defer profilesMutex.RUnlock()
+	p, found := profiles[name]
+	return p, found
+}
+
+// ProfileNames returns the names of the registered profiles, sorted
+func ProfileNames() []string {
+	profilesMutex.RLock()
+	defer profilesMutex.RUnlock()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Match says whether the path is selected by the profile
+func (p *Profile) Match(name string) bool {
+	for _, pattern := range p.Paths {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSource says whether the text file of the archive with the given
+// name is relevant for the profile
+func (p *Profile) MatchSource(name string) bool {
+	if len(p.Sources) == 0 {
+		return true
+	}
+	for _, pattern := range p.Sources {
+		if matched, _ := path.Match(pattern, path.Base(name)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Extend returns a copy of the profile with more patterns added
+func (p *Profile) Extend(name string, paths ...string) *Profile {
+	extended := &Profile{Name: name}
+	extended.Paths = append(extended.Paths, p.Paths...)
+	extended.Paths = append(extended.Paths, paths...)
+	extended.Sources = append(extended.Sources, p.Sources...)
+	extended.Memory = p.Memory
+	return extended
+}
+
+// PathHandler returns a PathHandlerFunc that ignores the files not
+// selected by the profile. Selected paths are passed to next, if not
+// nil.
+func (p *Profile) PathHandler(next PathHandlerFunc) PathHandlerFunc {
+	return func(name string) (string, error) {
+		if !p.Match(name) {
+			return "", nil
+		}
+		if next != nil {
+			return next(name)
+		}
+		return name, nil
+	}
+}
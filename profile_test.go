@@ -0,0 +1,84 @@
+package supportconfig_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type profileSuite struct {
+}
+
+var _ = Suite(&profileSuite{})
+
+func (ps *profileSuite) TestTriageProfileMatch(c *C) {
+	p := supportconfig.TriageProfile
+	c.Assert(p.Match("/etc/os-release"), Equals, true)
+	c.Assert(p.Match("/var/log/messages"), Equals, true)
+	c.Assert(p.Match("/etc/passwd"), Equals, false)
+}
+
+func (ps *profileSuite) TestProfileExtend(c *C) {
+	p := supportconfig.TriageProfile.Extend("appliance", "/var/log/appliance/*.log")
+	c.Assert(p.Name, Equals, "appliance")
+	c.Assert(p.Match("/var/log/appliance/agent.log"), Equals, true)
+	c.Assert(p.Match("/etc/fstab"), Equals, true)
+	c.Assert(supportconfig.TriageProfile.Match("/var/log/appliance/agent.log"), Equals, false)
+}
+
+func (ps *profileSuite) TestSplitterProfile(c *C) {
+	base := c.MkDir()
+	gotPath := make([]string, 0)
+	handler := func(path string) (string, error) {
+		gotPath = append(gotPath, path)
+		return path, nil
+	}
+	profile := &supportconfig.Profile{Name: "release", Paths: []string{"/etc/os-*"}}
+	config := supportconfig.Config{Base: base, PathHandler: profile.PathHandler(handler)}
+	splitter := &supportconfig.Splitter{Config: config}
+
+	err := splitter.Split(strings.NewReader(sampleMultipleFiles))
+	c.Assert(err, IsNil)
+	c.Assert(gotPath, DeepEquals, []string{"/etc/os-release"})
+
+	b, err := ioutil.ReadFile(filepath.Join(base, "/etc/os-release"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, osRelease)
+	_, err = ioutil.ReadFile(filepath.Join(base, "/etc/SuSE-release"))
+	c.Assert(err, NotNil)
+}
+
+func (ps *profileSuite) TestLookupProfile(c *C) {
+	names := strings.Join(supportconfig.ProfileNames(), " ")
+	for _, name := range []string{"kernel", "kubernetes", "network", "sap", "storage", "triage"} {
+		c.Assert(strings.Contains(names, name), Equals, true, Commentf("profile %s", name))
+	}
+
+	p, found := supportconfig.LookupProfile("network")
+	c.Assert(found, Equals, true)
+	c.Assert(p, Equals, supportconfig.NetworkProfile)
+	c.Assert(p.Match("/etc/sysconfig/network/ifcfg-eth0"), Equals, true)
+	c.Assert(p.Match("/etc/fstab"), Equals, false)
+
+	_, found = supportconfig.LookupProfile("printing")
+	c.Assert(found, Equals, false)
+}
+
+func (ps *profileSuite) TestRegisterProfile(c *C) {
+	custom := &supportconfig.Profile{Name: "custom-appliance", Paths: []string{"/opt/appliance/etc/*"}}
+	supportconfig.RegisterProfile(custom)
+	p, found := supportconfig.LookupProfile("custom-appliance")
+	c.Assert(found, Equals, true)
+	c.Assert(p.Match("/opt/appliance/etc/agent.conf"), Equals, true)
+}
+
+func (ps *profileSuite) TestProfileMatchSource(c *C) {
+	p := supportconfig.StorageProfile
+	c.Assert(p.MatchSource("fs-diskio.txt"), Equals, true)
+	c.Assert(p.MatchSource("/tmp/scc_node_190407_2023/lvm.txt"), Equals, true)
+	c.Assert(p.MatchSource("network.txt"), Equals, false)
+	c.Assert(supportconfig.KubernetesProfile.MatchSource("network.txt"), Equals, true)
+}
@@ -0,0 +1,211 @@
+package supportconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"iter"
+)
+
+// errStopped closes the body of the current section when the loop over
+// the sections is left early
+var errStopped = errors.New("iteration stopped")
+
+// withHandler returns a copy of the parser calling only the given handler
+// for all the sections
+func (p *Parser) withHandler(handler SectionHandlerFunc) *Parser {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return &Parser{
+		handlers:        make(map[string][]SectionHandlerFunc),
+		defaults:        []SectionHandlerFunc{handler},
+		statsHandlers:   p.statsHandlers,
+		untimedStats:    p.untimedStats,
+		warningHandlers: p.warningHandlers,
+		noteHandlers:    p.noteHandlers,
+		startHandlers:   p.startHandlers,
+		endHandlers:     p.endHandlers,
+		bufferSize:      p.bufferSize,
+		maxLineSize:     p.maxLineSize,
+		truncateLong:    p.truncateLong,
+		strict:          p.strict,
+		stripCR:         p.stripCR,
+		multiline:       p.multiline,
+		notes:           p.notes,
+		banner:          p.banner,
+		bannerPrefix:    p.bannerPrefix,
+		progress:        p.progress,
+	}
+}
+
+// Sections returns an iterator over the sections of the source, for code
+// that prefers ranging over them to registering handlers. Every section
+// with a header is yielded, with Body set to a reader for its contents,
+// which is only valid until the next iteration. Parsing errors are
+// yielded last, along with an empty Section.
+//
+// The handlers registered in the parser are not called, while the
+// options and the warning, stats and section start and end functions are
+// used as in Parse.
+func (p *Parser) Sections(source io.Reader) iter.Seq2[Section, error] {
+	return p.SectionsContext(context.Background(), source)
+}
+
+// SectionsContext works as Sections but stops as soon as the context is
+// done
+func (p *Parser) SectionsContext(ctx context.Context, source io.Reader) iter.Seq2[Section, error] {
+	return func(yield func(Section, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		sections := make(chan Section)
+		done := make(chan error, 1)
+
+		parser := p.withHandler(func(section Section) (io.WriteCloser, error) {
+			r, w := io.Pipe()
+			section.Body = r
+			// the header is reused by the parser for the next section
+			section.Header = append([]string(nil), section.Header...)
+			select {
+			case sections <- section:
+				return w, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+		go func() {
+			done <- parser.ParseContext(ctx, source)
+			close(sections)
+		}()
+
+		for section := range sections {
+			body := section.Body.(*io.PipeReader)
+			if !yield(section, nil) {
+				cancel()
+				body.CloseWithError(errStopped)
+				<-done
+				return
+			}
+			// the body might have been left unread
+			io.Copy(io.Discard, body)
+		}
+		if err := <-done; err != nil {
+			yield(Section{}, err)
+		}
+	}
+}
+
+// EventKind tells what an Event is about
+type EventKind int
+
+const (
+	// EventSectionStart is sent when a section starts, once its
+	// header is known
+	EventSectionStart EventKind = iota
+
+	// EventLine is sent for every line of the body of a section
+	EventLine
+
+	// EventSectionEnd is sent when a section ends
+	EventSectionEnd
+
+	// EventError is sent when the parsing fails, as the last event
+	EventError
+)
+
+// Event is sent by Events while parsing a source
+type Event struct {
+	Kind EventKind
+
+	// Section is the section the event is about. Its Body is not set.
+	Section Section
+
+	// Line is the line of the body, including its line break, for
+	// EventLine events
+	Line []byte
+
+	// Lines and Bytes are the size of the body, for EventSectionEnd events
+	Lines int64
+	Bytes int64
+
+	// Err is the parsing error, for EventError events
+	Err error
+}
+
+// Events parses the source in a new goroutine, sending the sections and
+// the lines of their bodies as events in the returned channel, which is
+// closed at the end. The events own their data, so they can be handed
+// to other goroutines. As with Sections, the handlers registered in the
+// parser are not called.
+func (p *Parser) Events(source io.Reader) <-chan Event {
+	return p.EventsContext(context.Background(), source)
+}
+
+// EventsContext works as Events but stops as soon as the context is done,
+// which is how consumers leaving early let the parsing goroutine end
+func (p *Parser) EventsContext(ctx context.Context, source io.Reader) <-chan Event {
+	events := make(chan Event)
+	send := func(e Event) error {
+		select {
+		case events <- e:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	parser := p.withHandler(func(section Section) (io.WriteCloser, error) {
+		section.Header = append([]string(nil), section.Header...)
+		if err := send(Event{Kind: EventSectionStart, Section: section}); err != nil {
+			return nil, err
+		}
+		return &eventWriter{section: section, send: send}, nil
+	})
+	go func() {
+		defer close(events)
+		if err := parser.ParseContext(ctx, source); err != nil && ctx.Err() == nil {
+			send(Event{Kind: EventError, Err: err})
+		}
+	}()
+	return events
+}
+
+// eventWriter collects the body of a section, sending an EventLine event
+// for every line and an EventSectionEnd event when closed
+type eventWriter struct {
+	section Section
+	send    func(e Event) error
+	partial []byte
+	lines   int64
+	bytes   int64
+}
+
+func (w *eventWriter) Write(b []byte) (int, error) {
+	w.bytes += int64(len(b))
+	w.partial = append(w.partial, b...)
+	if !bytes.HasSuffix(b, []byte("\n")) {
+		return len(b), nil
+	}
+	if err := w.flush(); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (w *eventWriter) flush() error {
+	if len(w.partial) == 0 {
+		return nil
+	}
+	w.lines++
+	line := w.partial
+	w.partial = nil
+	return w.send(Event{Kind: EventLine, Section: w.section, Line: line})
+}
+
+func (w *eventWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.send(Event{Kind: EventSectionEnd, Section: w.section, Lines: w.lines, Bytes: w.bytes})
+}
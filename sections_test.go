@@ -0,0 +1,125 @@
+package supportconfig_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type sectionsSuite struct {
+}
+
+var _ = Suite(&sectionsSuite{})
+
+func (ss *sectionsSuite) TestSections(c *C) {
+	var names, headers []string
+	p := supportconfig.NewParser()
+	for section, err := range p.Sections(strings.NewReader(sampleMultipleFiles)) {
+		c.Assert(err, IsNil)
+		names = append(names, section.Name)
+		headers = append(headers, section.Header[0])
+		if section.Header[0] == "# /etc/os-release" {
+			b, err := ioutil.ReadAll(section.Body)
+			c.Assert(err, IsNil)
+			c.Assert(string(b), Equals, osRelease+UglyExtraNewlines)
+		}
+	}
+	c.Assert(names, DeepEquals, []string{"Command", "Command", "Configuration File", "Configuration File", "System"})
+	c.Assert(headers[3], Equals, "# /etc/os-release")
+}
+
+func (ss *sectionsSuite) TestSectionsBreak(c *C) {
+	count := 0
+	p := supportconfig.NewParser()
+	for section, err := range p.Sections(strings.NewReader(sampleMultipleGroups)) {
+		c.Assert(err, IsNil)
+		c.Assert(section.Name, Equals, "Command")
+		count++
+		break
+	}
+	c.Assert(count, Equals, 1)
+}
+
+func (ss *sectionsSuite) TestSectionKind(c *C) {
+	var kinds []string
+	p := supportconfig.NewParser()
+	for section, err := range p.Sections(strings.NewReader(sampleMultipleFiles + logEntryNotFound)) {
+		c.Assert(err, IsNil)
+		kinds = append(kinds, section.Kind.String()+" "+section.Path)
+	}
+	c.Assert(kinds, DeepEquals, []string{
+		"Command ",
+		"Command ",
+		"Configuration File /etc/SuSE-release",
+		"Configuration File /etc/os-release",
+		"Unknown ",
+		"Log File ",
+	})
+	c.Assert(supportconfig.KindOf("Verification"), Equals, supportconfig.KindVerification)
+}
+
+func (ss *sectionsSuite) TestSectionsError(c *C) {
+	var last error
+	p := supportconfig.NewParser(supportconfig.WithStrictBanners())
+	for section, err := range p.Sections(strings.NewReader(sampleOddities)) {
+		if err != nil {
+			c.Assert(section.Name, Equals, "")
+			last = err
+		}
+	}
+	c.Assert(errors.Is(last, supportconfig.ErrMalformedBanner), Equals, true)
+}
+
+func (ss *sectionsSuite) TestSectionsIgnoreHandlers(c *C) {
+	called := false
+	p := supportconfig.NewParser()
+	p.HandleDefault(func(name, after string) (io.WriteCloser, error) {
+		called = true
+		return nil, nil
+	})
+	for _, err := range p.Sections(strings.NewReader(sampleMultipleGroups)) {
+		c.Assert(err, IsNil)
+	}
+	c.Assert(called, Equals, false)
+}
+
+func (ss *sectionsSuite) TestEvents(c *C) {
+	var kinds []supportconfig.EventKind
+	var lines []string
+	p := supportconfig.NewParser(supportconfig.WithBufferSize(16))
+	for event := range p.Events(strings.NewReader(sampleMultipleGroups)) {
+		c.Assert(event.Err, IsNil)
+		kinds = append(kinds, event.Kind)
+		switch event.Kind {
+		case supportconfig.EventLine:
+			if event.Section.Header[0] == "# /bin/uname -a" {
+				lines = append(lines, string(event.Line))
+			}
+		case supportconfig.EventSectionEnd:
+			if event.Section.Header[0] == "# /bin/uname -a" {
+				c.Assert(event.Lines, Equals, int64(2))
+				c.Assert(event.Bytes, Equals, int64(len(strings.Join(lines, ""))))
+			}
+		}
+	}
+	c.Assert(kinds[:4], DeepEquals, []supportconfig.EventKind{
+		supportconfig.EventSectionStart, supportconfig.EventLine, supportconfig.EventLine, supportconfig.EventSectionEnd,
+	})
+	c.Assert(lines, HasLen, 2)
+	c.Assert(strings.HasPrefix(lines[0], "Linux node 4.4.121-92.85-default #1 SMP"), Equals, true)
+	c.Assert(lines[1], Equals, "\n")
+}
+
+func (ss *sectionsSuite) TestEventsError(c *C) {
+	var last supportconfig.Event
+	p := supportconfig.NewParser(supportconfig.WithStrictBanners())
+	for event := range p.Events(strings.NewReader(sampleOddities)) {
+		last = event
+	}
+	c.Assert(last.Kind, Equals, supportconfig.EventError)
+	c.Assert(errors.Is(last.Err, supportconfig.ErrMalformedBanner), Equals, true)
+}
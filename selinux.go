@@ -0,0 +1,207 @@
+package supportconfig
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// maxDenials is how many of the most recent SELinux denials are kept
+const maxDenials = 100
+
+// SELinuxStatus has the SELinux state found in a supportconfig, such as
+// the ones of SLE Micro
+type SELinuxStatus struct {
+	// Enabled says whether SELinux is enabled in the running kernel
+	Enabled bool
+
+	// Mode is the current mode: enforcing, permissive or disabled
+	Mode string
+
+	// ConfigMode is the mode set in /etc/selinux/config, used on boot
+	ConfigMode string
+
+	// Policy is the name of the policy, such as targeted
+	Policy string
+
+	// Denials are the most recent access denials found in the logs,
+	// oldest first
+	Denials []Denial
+}
+
+// Denial is an access denied by SELinux, as logged in an AVC message
+type Denial struct {
+	// Permissions are the permissions denied, such as read
+	Permissions []string
+
+	// Command is the command that was denied access
+	Command string
+
+	// Source and Target are the security contexts of the process and
+	// of the object accessed, of the given Class
+	Source string
+	Target string
+	Class  string
+
+	// Permissive says whether the access was allowed anyway
+	Permissive bool
+
+	// Message is the log line
+	Message string
+}
+
+var (
+	denialRe      = regexp.MustCompile(`avc:\s+denied\s+\{([^}]*)\}`)
+	denialFieldRe = regexp.MustCompile(`\b(comm|scontext|tcontext|tclass|permissive)=("[^"]*"|\S+)`)
+)
+
+// parseDenial parses an AVC message, returning false for other lines
+func parseDenial(line string) (Denial, bool) {
+	found := denialRe.FindStringSubmatch(line)
+	if found == nil {
+		return Denial{}, false
+	}
+	denial := Denial{Permissions: strings.Fields(found[1]), Message: line}
+	for _, field := range denialFieldRe.FindAllStringSubmatch(line, -1) {
+		value := strings.Trim(field[2], `"`)
+		switch field[1] {
+		case "comm":
+			denial.Command = value
+		case "scontext":
+			denial.Source = value
+		case "tcontext":
+			denial.Target = value
+		case "tclass":
+			denial.Class = value
+		case "permissive":
+			denial.Permissive = value == "1"
+		}
+	}
+	return denial, true
+}
+
+// ParseSELinux reads the SELinux status from the output of sestatus, the
+// files in /etc/selinux and /sys/fs/selinux, and the denials logged in
+// the Log File sections of the source
+func ParseSELinux(source io.Reader) (*SELinuxStatus, error) {
+	status := &SELinuxStatus{}
+	if err := Analyze(source, SELinuxAnalyzer(status)); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// SELinuxAnalyzer works as ParseSELinux, filling status
+func SELinuxAnalyzer(status *SELinuxStatus) Analyzer {
+	return func(p *Parser) func() error {
+		p.HandleSection("Configuration File", status.fileHandler)
+		p.HandleSection("Command", status.sestatusHandler)
+		p.HandleSection("Log File", status.logHandler)
+		return noDone
+	}
+}
+
+func (s *SELinuxStatus) fileHandler(section, afterline string) (io.WriteCloser, error) {
+	name, err := afterlineToPath(strings.TrimPrefix(afterline, "# "))
+	if err != nil {
+		return nil, err
+	}
+	switch path.Clean(name) {
+	case "/etc/selinux/config":
+		return &treeWriter{done: s.setConfig}, nil
+	case "/sys/fs/selinux/enforce":
+		return &treeWriter{done: s.setEnforce}, nil
+	}
+	return nil, nil
+}
+
+// setConfig reads lines such as SELINUX=enforcing, keeping what was
+// already read from sestatus
+func (s *SELinuxStatus) setConfig(body []byte) {
+	for _, line := range configLines(string(body)) {
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[idx+1:])
+		switch strings.TrimSpace(line[:idx]) {
+		case "SELINUX":
+			if s.ConfigMode == "" {
+				s.ConfigMode = value
+			}
+		case "SELINUXTYPE":
+			if s.Policy == "" {
+				s.Policy = value
+			}
+		}
+	}
+}
+
+func (s *SELinuxStatus) setEnforce(body []byte) {
+	if s.Mode != "" {
+		return
+	}
+	s.Enabled = true
+	switch string(bytes.TrimSpace(body)) {
+	case "1":
+		s.Mode = "enforcing"
+	case "0":
+		s.Mode = "permissive"
+	}
+}
+
+func (s *SELinuxStatus) sestatusHandler(section, afterline string) (io.WriteCloser, error) {
+	fields := strings.Fields(strings.TrimPrefix(afterline, "# "))
+	if len(fields) == 0 || path.Base(fields[0]) != "sestatus" {
+		return nil, nil
+	}
+	return &treeWriter{done: s.setStatus}, nil
+}
+
+// setStatus reads the output of sestatus, which has precedence over
+// what is found in the files
+func (s *SELinuxStatus) setStatus(body []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		idx := strings.Index(scanner.Text(), ":")
+		if idx < 0 {
+			continue
+		}
+		value := strings.TrimSpace(scanner.Text()[idx+1:])
+		switch strings.TrimSpace(scanner.Text()[:idx]) {
+		case "SELinux status":
+			s.Enabled = value == "enabled"
+			if !s.Enabled {
+				s.Mode = "disabled"
+			}
+		case "Current mode":
+			s.Mode = value
+		case "Mode from config file":
+			s.ConfigMode = value
+		case "Loaded policy name":
+			s.Policy = value
+		}
+	}
+}
+
+func (s *SELinuxStatus) logHandler(section, afterline string) (io.WriteCloser, error) {
+	return &lineWriter{line: s.denialLine}, nil
+}
+
+// denialLine looks for a denial in a line of a log
+func (s *SELinuxStatus) denialLine(line []byte) {
+	if !bytes.Contains(line, []byte("avc:")) {
+		return
+	}
+	denial, found := parseDenial(strings.TrimRight(string(line), "\r"))
+	if !found {
+		return
+	}
+	s.Denials = append(s.Denials, denial)
+	if len(s.Denials) > maxDenials {
+		s.Denials = s.Denials[1:]
+	}
+}
@@ -0,0 +1,100 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type selinuxSuite struct {
+}
+
+var _ = Suite(&selinuxSuite{})
+
+const sampleSELinux = `#==[ Command ]======================================#
+# /usr/sbin/sestatus
+SELinux status:                 enabled
+SELinuxfs mount:                /sys/fs/selinux
+SELinux root directory:         /etc/selinux
+Loaded policy name:             targeted
+Current mode:                   permissive
+Mode from config file:          enforcing
+Policy MLS status:              enabled
+
+#==[ Configuration File ]===========================#
+# /etc/selinux/config
+# This file controls the state of SELinux on the system.
+SELINUX=enforcing
+SELINUXTYPE=targeted
+
+#==[ Log File ]=====================================#
+# /var/log/audit/audit.log - Last 3 Lines
+type=AVC msg=audit(1690000000.123:456): avc:  denied  { read write } for  pid=1234 comm="httpd" name="index.html" dev="sda2" ino=42 scontext=system_u:system_r:httpd_t:s0 tcontext=unconfined_u:object_r:user_home_t:s0 tclass=file permissive=1
+type=SYSCALL msg=audit(1690000000.123:456): arch=c000003e syscall=2 success=yes
+type=AVC msg=audit(1690000001.000:457): avc:  denied  { name_bind } for  pid=99 comm="sshd" src=2222 scontext=system_u:system_r:sshd_t:s0 tcontext=system_u:object_r:unreserved_port_t:s0 tclass=tcp_socket permissive=0
+
+`
+
+func (ss *selinuxSuite) TestParseSELinux(c *C) {
+	status, err := supportconfig.ParseSELinux(strings.NewReader(sampleSELinux))
+	c.Assert(err, IsNil)
+	c.Assert(status.Enabled, Equals, true)
+	c.Assert(status.Mode, Equals, "permissive")
+	c.Assert(status.ConfigMode, Equals, "enforcing")
+	c.Assert(status.Policy, Equals, "targeted")
+	c.Assert(status.Denials, HasLen, 2)
+	c.Assert(status.Denials[0].Message, Matches, "type=AVC .*index.html.*")
+	status.Denials[0].Message = ""
+	c.Assert(status.Denials[0], DeepEquals, supportconfig.Denial{
+		Permissions: []string{"read", "write"},
+		Command:     "httpd",
+		Source:      "system_u:system_r:httpd_t:s0",
+		Target:      "unconfined_u:object_r:user_home_t:s0",
+		Class:       "file",
+		Permissive:  true,
+	})
+	c.Assert(status.Denials[1].Command, Equals, "sshd")
+	c.Assert(status.Denials[1].Class, Equals, "tcp_socket")
+	c.Assert(status.Denials[1].Permissive, Equals, false)
+}
+
+func (ss *selinuxSuite) TestParseSELinuxDisabled(c *C) {
+	source := `#==[ Command ]======================================#
+# /usr/sbin/sestatus
+SELinux status:                 disabled
+
+#==[ Configuration File ]===========================#
+# /sys/fs/selinux/enforce - File not found
+
+`
+	status, err := supportconfig.ParseSELinux(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	c.Assert(status.Enabled, Equals, false)
+	c.Assert(status.Mode, Equals, "disabled")
+	c.Assert(status.Denials, HasLen, 0)
+}
+
+func (ss *selinuxSuite) TestParseSELinuxPrecedence(c *C) {
+	status := `#==[ Command ]======================================#
+# /usr/sbin/sestatus
+SELinux status:                 enabled
+Loaded policy name:             targeted
+Current mode:                   enforcing
+Mode from config file:          enforcing
+
+`
+	config := `#==[ Configuration File ]===========================#
+# /etc/selinux/config
+SELINUX=permissive
+SELINUXTYPE=minimum
+
+`
+	// sestatus wins over the configuration file, whatever their order
+	for _, source := range []string{status + config, config + status} {
+		parsed, err := supportconfig.ParseSELinux(strings.NewReader(source))
+		c.Assert(err, IsNil)
+		c.Assert(parsed.ConfigMode, Equals, "enforcing")
+		c.Assert(parsed.Policy, Equals, "targeted")
+	}
+}
@@ -0,0 +1,221 @@
+package supportconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SocketSummary sums up the TCP sockets listed by ss or netstat
+type SocketSummary struct {
+	// Command is the command the sockets were listed with
+	Command string
+
+	// States are the number of sockets in each state, such as
+	// ESTABLISHED, TIME_WAIT or SYN_RECV
+	States map[string]int
+
+	// Listeners are the listening sockets, sorted by port
+	Listeners []Listener
+
+	// PortRange is the range of the ephemeral ports, from
+	// net.ipv4.ip_local_port_range, and SynBacklog is
+	// net.ipv4.tcp_max_syn_backlog. They are zero when not found.
+	PortRange  [2]int
+	SynBacklog int
+}
+
+// Listener is a listening TCP socket
+type Listener struct {
+	Address string
+	Port    int
+
+	// Queue is the number of connections waiting to be accepted, and
+	// Backlog how many of them can wait
+	Queue   int
+	Backlog int
+
+	// Process is the process listening, as listed, when known
+	Process string
+}
+
+// QueueFull says whether the connections to the listener are being
+// dropped because they are not accepted fast enough
+func (l Listener) QueueFull() bool {
+	return l.Backlog > 0 && l.Queue >= l.Backlog
+}
+
+// ParseSockets reads the sockets listed by ss, or by netstat when ss is
+// not found, and the sysctls limiting them, found in the source
+func ParseSockets(source io.Reader) (*SocketSummary, error) {
+	summary := &SocketSummary{}
+	if err := Analyze(source, SocketsAnalyzer(summary)); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// SocketsAnalyzer works as ParseSockets, filling summary
+func SocketsAnalyzer(summary *SocketSummary) Analyzer {
+	return func(p *Parser) func() error {
+		tree := newTreeOf("/proc/sys/net/ipv4/ip_local_port_range", "/proc/sys/net/ipv4/tcp_max_syn_backlog")
+		summary.States = make(map[string]int)
+		fromSS := false
+		p.HandleSection("Command", tree.sysctlHandler)
+		p.HandleSection("Command", func(section, afterline string) (io.WriteCloser, error) {
+			command := strings.TrimPrefix(afterline, "# ")
+			fields := strings.Fields(command)
+			if len(fields) == 0 {
+				return nil, nil
+			}
+			ss := path.Base(fields[0]) == "ss"
+			if !ss && path.Base(fields[0]) != "netstat" || fromSS {
+				return nil, nil
+			}
+			return &treeWriter{done: func(body []byte) {
+				// the first listing with TCP sockets is kept, and ss is
+				// preferred to netstat
+				parsed := parseSockets(body)
+				if len(parsed.States) == 0 || summary.Command != "" && !ss {
+					return
+				}
+				parsed.Command = command
+				*summary = parsed
+				fromSS = ss
+			}}, nil
+		})
+		return func() error {
+			if value, found := tree.Get("/proc/sys/net/ipv4/ip_local_port_range"); found {
+				fields := strings.Fields(value)
+				if len(fields) == 2 {
+					summary.PortRange[0], _ = strconv.Atoi(fields[0])
+					summary.PortRange[1], _ = strconv.Atoi(fields[1])
+				}
+			}
+			if value, found := tree.Get("/proc/sys/net/ipv4/tcp_max_syn_backlog"); found {
+				summary.SynBacklog, _ = strconv.Atoi(value)
+			}
+			return nil
+		}
+	}
+}
+
+// socketStates are the states as listed by ss, which netstat lists with
+// underscores instead
+var socketStates = map[string]string{
+	"ESTAB":      "ESTABLISHED",
+	"UNCONN":     "",
+	"SYN-SENT":   "SYN_SENT",
+	"SYN-RECV":   "SYN_RECV",
+	"FIN-WAIT-1": "FIN_WAIT1",
+	"FIN-WAIT-2": "FIN_WAIT2",
+	"TIME-WAIT":  "TIME_WAIT",
+	"CLOSE-WAIT": "CLOSE_WAIT",
+	"LAST-ACK":   "LAST_ACK",
+}
+
+// parseSockets reads the TCP sockets in the output of ss or netstat,
+// telling which one by the columns of their header
+func parseSockets(body []byte) SocketSummary {
+	summary := SocketSummary{States: make(map[string]int)}
+	// the columns of the netid, the state, the queues, the local
+	// address and the process, or -1 when missing
+	netid, state, queue, local, process := -1, -1, -1, -1, -1
+	ss := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "Netid":
+			netid, state, queue, local, process, ss = 0, 1, 2, 4, 6, true
+			continue
+		case "State":
+			netid, state, queue, local, process, ss = -1, 0, 1, 3, 5, true
+			continue
+		case "Proto":
+			netid, state, queue, local, process, ss = 0, 5, 1, 3, 6, false
+			continue
+		}
+		if state < 0 || len(fields) <= local || len(fields) <= state {
+			continue
+		}
+		if netid >= 0 && !strings.HasPrefix(fields[netid], "tcp") {
+			continue
+		}
+		name := fields[state]
+		if normalized, found := socketStates[name]; found {
+			name = normalized
+		}
+		if name == "" {
+			continue
+		}
+		summary.States[name]++
+		if name != "LISTEN" {
+			continue
+		}
+		idx := strings.LastIndex(fields[local], ":")
+		if idx < 0 {
+			continue
+		}
+		listener := Listener{Address: fields[local][:idx]}
+		listener.Port, _ = strconv.Atoi(fields[local][idx+1:])
+		listener.Queue, _ = strconv.Atoi(fields[queue])
+		// ss lists the backlog of listeners as their send queue,
+		// netstat doesn't list it
+		if ss {
+			listener.Backlog, _ = strconv.Atoi(fields[queue+1])
+		}
+		if process < len(fields) {
+			listener.Process = strings.Join(fields[process:], " ")
+		}
+		summary.Listeners = append(summary.Listeners, listener)
+	}
+	sort.SliceStable(summary.Listeners, func(i, j int) bool {
+		return summary.Listeners[i].Port < summary.Listeners[j].Port
+	})
+	return summary
+}
+
+// CheckSockets looks for the exhaustion of the ephemeral ports, mostly
+// by sockets in TIME_WAIT, for SYN_RECV backlogs and for listeners whose
+// accept queue is full
+func CheckSockets(summary *SocketSummary) []Finding {
+	var findings []Finding
+	if ports := summary.PortRange[1] - summary.PortRange[0] + 1; summary.PortRange[1] > 0 && ports > 0 {
+		used := 0
+		for state, n := range summary.States {
+			if state != "LISTEN" {
+				used += n
+			}
+		}
+		if used*10 >= ports*8 {
+			findings = append(findings, Finding{Path: "/proc/sys/net/ipv4/ip_local_port_range",
+				Value:   fmt.Sprintf("%d %d", summary.PortRange[0], summary.PortRange[1]),
+				Message: fmt.Sprintf("%d sockets are open, the ephemeral ports are running out", used)})
+		}
+		if tw := summary.States["TIME_WAIT"]; tw*2 >= ports {
+			findings = append(findings, Finding{Path: summary.Command, Value: fmt.Sprintf("%d TIME_WAIT", tw),
+				Message: "sockets in TIME_WAIT should not hold half of the ephemeral ports"})
+		}
+	}
+	if syn := summary.States["SYN_RECV"]; summary.SynBacklog > 0 && syn*2 >= summary.SynBacklog {
+		findings = append(findings, Finding{Path: "/proc/sys/net/ipv4/tcp_max_syn_backlog", Value: strconv.Itoa(summary.SynBacklog),
+			Message: fmt.Sprintf("%d connections in SYN_RECV fill half of the SYN backlog", syn)})
+	}
+	for _, listener := range summary.Listeners {
+		if listener.QueueFull() {
+			findings = append(findings, Finding{Path: summary.Command,
+				Value:   fmt.Sprintf("%s:%d %d/%d", listener.Address, listener.Port, listener.Queue, listener.Backlog),
+				Message: "accept queue of the listener is full, connections are dropped"})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,85 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type socketsSuite struct {
+}
+
+var _ = Suite(&socketsSuite{})
+
+const sampleNetstat = `#==[ Command ]======================================#
+# /bin/netstat -nap
+Active Internet connections (servers and established)
+Proto Recv-Q Send-Q Local Address           Foreign Address         State       PID/Program name
+tcp        0      0 0.0.0.0:22              0.0.0.0:*               LISTEN      812/sshd
+tcp        0      0 10.0.0.1:22             10.0.0.2:51234          ESTABLISHED 901/sshd: root
+udp        0      0 0.0.0.0:68              0.0.0.0:*                           640/wickedd-dhcp4
+Active UNIX domain sockets (servers and established)
+Proto RefCnt Flags       Type       State         I-Node   PID/Program name     Path
+unix  2      [ ACC ]     STREAM     LISTENING     17321    1/systemd            /run/systemd/private
+
+`
+
+const sampleSS = `#==[ Command ]======================================#
+# /sbin/sysctl -a
+net.ipv4.ip_local_port_range = 60000	60009
+net.ipv4.tcp_max_syn_backlog = 4
+
+#==[ Command ]======================================#
+# /usr/bin/ss -anp
+Netid State      Recv-Q Send-Q Local Address:Port   Peer Address:Port  Process
+u_str ESTAB      0      0      * 17321               * 17322
+udp   UNCONN     0      0      0.0.0.0:68            0.0.0.0:*
+tcp   LISTEN     0      128    0.0.0.0:22            0.0.0.0:*          users:(("sshd",pid=812,fd=3))
+tcp   LISTEN     129    128    [::]:8080             [::]:*             users:(("java",pid=1200,fd=40))
+tcp   ESTAB      0      0      10.0.0.1:22           10.0.0.2:51234
+tcp   SYN-RECV   0      0      10.0.0.1:8080         10.0.0.9:40001
+tcp   SYN-RECV   0      0      10.0.0.1:8080         10.0.0.9:40002
+tcp   TIME-WAIT  0      0      10.0.0.1:60001        10.0.0.3:80
+tcp   TIME-WAIT  0      0      10.0.0.1:60002        10.0.0.3:80
+tcp   TIME-WAIT  0      0      10.0.0.1:60003        10.0.0.3:80
+tcp   TIME-WAIT  0      0      10.0.0.1:60004        10.0.0.3:80
+tcp   TIME-WAIT  0      0      10.0.0.1:60005        10.0.0.3:80
+
+`
+
+func (ss *socketsSuite) TestParseSockets(c *C) {
+	summary, err := supportconfig.ParseSockets(strings.NewReader(sampleNetstat + sampleSS))
+	c.Assert(err, IsNil)
+	c.Assert(summary.Command, Equals, "/usr/bin/ss -anp")
+	c.Assert(summary.States, DeepEquals, map[string]int{"LISTEN": 2, "ESTABLISHED": 1, "SYN_RECV": 2, "TIME_WAIT": 5})
+	c.Assert(summary.PortRange, Equals, [2]int{60000, 60009})
+	c.Assert(summary.SynBacklog, Equals, 4)
+	c.Assert(summary.Listeners, DeepEquals, []supportconfig.Listener{
+		{Address: "0.0.0.0", Port: 22, Backlog: 128, Process: `users:(("sshd",pid=812,fd=3))`},
+		{Address: "[::]", Port: 8080, Queue: 129, Backlog: 128, Process: `users:(("java",pid=1200,fd=40))`},
+	})
+	c.Assert(summary.Listeners[1].QueueFull(), Equals, true)
+
+	summary, err = supportconfig.ParseSockets(strings.NewReader(sampleNetstat))
+	c.Assert(err, IsNil)
+	c.Assert(summary.States, DeepEquals, map[string]int{"LISTEN": 1, "ESTABLISHED": 1})
+	c.Assert(summary.Listeners, DeepEquals, []supportconfig.Listener{
+		{Address: "0.0.0.0", Port: 22, Process: "812/sshd"},
+	})
+}
+
+func (ss *socketsSuite) TestCheckSockets(c *C) {
+	summary, err := supportconfig.ParseSockets(strings.NewReader(sampleSS))
+	c.Assert(err, IsNil)
+	var findings []string
+	for _, finding := range supportconfig.CheckSockets(summary) {
+		findings = append(findings, finding.Value)
+	}
+	c.Assert(findings, DeepEquals, []string{
+		"60000 60009",
+		"5 TIME_WAIT",
+		"4",
+		"[::]:8080 129/128",
+	})
+}
@@ -3,27 +3,438 @@ package supportconfig
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/opencontainers/runc/libcontainer/utils"
 )
 
+// defaultBufferSize is the size of the read buffer, lines longer than it
+// are passed to the collectors in chunks
+const defaultBufferSize = 64 * 1024
+
+// readerPool has readers with the default buffer size, which are too
+// big to be allocated for every source
+var readerPool = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewReaderSize(nil, defaultBufferSize)
+	},
+}
+
+// maxBannerSize is how much of a line looking like a banner is read to
+// check whether it really is one
+const maxBannerSize = 4096
+
 // HandlerFunc is the func that is used by HandleSection
 type HandlerFunc func(section, next string) (io.WriteCloser, error)
 
-// Parser keeps the state of the parsing accross different files
+// Section describes a section as found in the source, before its body
+type Section struct {
+	// Name is the name of the section, as found in the banner
+	Name string
+
+	// Kind is the kind of section, told by its name
+	Kind SectionKind
+
+	// Path is the path of the file embedded in Configuration File and
+	// Log File sections, or an empty string
+	Path string
+
+	// Banner is the raw banner line, without its line break
+	Banner string
+
+	// Header has the comment lines following the banner, without their
+	// line breaks. It is reused by the parser for the next section, so
+	// a handler keeping it past its call has to copy it.
+	Header []string
+
+	// Body reads the contents of the section. It is only set for the
+	// sections yielded by Sections.
+	Body io.Reader
+}
+
+// SectionKind tells what a section has
+type SectionKind int
+
+const (
+	// KindUnknown is the kind of the sections with names not known
+	KindUnknown SectionKind = iota
+
+	// KindConfigurationFile sections have the contents of a file
+	KindConfigurationFile
+
+	// KindLogFile sections have the contents, or the last lines, of a log
+	KindLogFile
+
+	// KindCommand sections have the output of a command
+	KindCommand
+
+	// KindVerification sections have the result of verifying a package
+	KindVerification
+
+	// KindNote sections have remarks of supportconfig itself
+	KindNote
+
+	// KindSummary sections have an overview written by supportconfig
+	KindSummary
+)
+
+// sectionKinds are the names of the sections of each kind
+var sectionKinds = map[string]SectionKind{
+	"Configuration File": KindConfigurationFile,
+	"Log File":           KindLogFile,
+	"Command":            KindCommand,
+	"Verification":       KindVerification,
+	"Note":               KindNote,
+	"Summary":            KindSummary,
+}
+
+// KindOf returns the kind of the sections with the given name
+func KindOf(name string) SectionKind {
+	return sectionKinds[name]
+}
+
+// embedsFile says whether the sections with the given name have a file as
+// their body, whose lines must be kept as they are
+func embedsFile(name string) bool {
+	kind := KindOf(name)
+	return kind == KindConfigurationFile || kind == KindLogFile
+}
+
+func (k SectionKind) String() string {
+	for name, kind := range sectionKinds {
+		if kind == k {
+			return name
+		}
+	}
+	return "Unknown"
+}
+
+// sectionPath returns the path of the file embedded in a section, or an
+// empty string when there is none
+func sectionPath(kind SectionKind, header string) string {
+	if kind != KindConfigurationFile && kind != KindLogFile {
+		return ""
+	}
+	name, err := afterlineToPath(strings.TrimPrefix(header, "# "))
+	if err != nil {
+		return ""
+	}
+	return utils.CleanPath(name)
+}
+
+// SectionHandlerFunc is the func that is used by HandleSectionFunc. It
+// works as HandlerFunc, but gets the raw banner and header lines.
+type SectionHandlerFunc func(section Section) (io.WriteCloser, error)
+
+// sectionHandler adapts a HandlerFunc to be kept along with the
+// SectionHandlerFuncs
+func (h HandlerFunc) sectionHandler() SectionHandlerFunc {
+	return func(section Section) (io.WriteCloser, error) {
+		var header string
+		if len(section.Header) > 0 {
+			header = section.Header[0]
+		}
+		return h(section.Name, header)
+	}
+}
+
+// SectionStats has the numbers collected for a section when it ends
+type SectionStats struct {
+	// Section is the name of the section, as found in the banner
+	Section string
+
+	// Header is the line following the banner
+	Header string
+
+	// Offset is the position of the section body in the source
+	Offset int64
+
+	// Bytes is the size of the section body, not counting the banner
+	// and header lines, and Lines is the number of lines of the body
+	Bytes int64
+	Lines int64
+
+	// Elapsed is the time spent parsing the section body, including
+	// the time spent by the handlers
+	Elapsed time.Duration
+
+	// Handlers has the time spent by each handler called for the
+	// section, in the order they were called. It includes the time
+	// spent writing to and closing the collector it returned.
+	Handlers []time.Duration
+}
+
+// StatsFunc is called by the parser at the end of every section. A
+// returned error stops the parsing, which can be used to enforce budgets.
+type StatsFunc func(stats SectionStats) error
+
+// Warning describes a recoverable oddity found in the source, which
+// doesn't stop the parsing but might mean data is missing or misplaced
+type Warning struct {
+	// Line is the line of the source where the problem was found,
+	// starting at 1. It is zero when not known.
+	Line int64
+
+	// Section is the name of the section where the problem was found,
+	// if any
+	Section string
+
+	// Message describes the problem
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Line == 0 {
+		return w.Message
+	}
+	return fmt.Sprintf("line %d: %s", w.Line, w.Message)
+}
+
+// WarningFunc is called by the parser for every warning found
+type WarningFunc func(w Warning)
+
+// SectionNote is a "# Note:" line found in the body of a section, read
+// with WithNotes
+type SectionNote struct {
+	// Line is the line of the source the note is in, starting at 1
+	Line int64
+
+	// Section and Header are the name and the header of the section
+	// the note is in
+	Section string
+	Header  string
+
+	// Text is the note, without the "# Note:" prefix
+	Text string
+}
+
+// NoteFunc is called by the parser for every note found
+type NoteFunc func(note SectionNote)
+
+// notePrefix starts the remarks supportconfig adds inside sections, such
+// as why their output is limited
+var notePrefix = []byte("# Note:")
+
+// ParseError tells where in the source the parsing failed
+type ParseError struct {
+	// Line is the line of the source being parsed, starting at 1
+	Line int64
+
+	// Offset is the position in the source where the line starts
+	Offset int64
+
+	// Section is the name of the section being parsed, if any
+	Section string
+
+	// Destination is the name of the collector that failed to be
+	// written or closed, when it has a Name method as *os.File has
+	Destination string
+
+	// Err is the error that stopped the parsing
+	Err error
+
+	// Checkpoint is where the parsing can be resumed with ParseFrom
+	Checkpoint Checkpoint
+}
+
+// Checkpoint is a position in the source where parsing can be resumed,
+// at the start of a line outside of any section or at the banner of the
+// section that was being parsed
+type Checkpoint struct {
+	// Offset is the position in the source
+	Offset int64
+
+	// Line is the number of lines before Offset
+	Line int64
+
+	// Sections is the number of sections found before Offset
+	Sections int64
+}
+
+func (e *ParseError) Error() string {
+	if e.Destination != "" {
+		return fmt.Sprintf("line %d (offset %d) in section %q, writing to %s: %v", e.Line, e.Offset, e.Section, e.Destination, e.Err)
+	}
+	if e.Section == "" {
+		return fmt.Sprintf("line %d (offset %d): %v", e.Line, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("line %d (offset %d) in section %q: %v", e.Line, e.Offset, e.Section, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// SectionStartFunc is called when a section starts, once its header is
+// known
+type SectionStartFunc func(section, header string)
+
+// SectionEndFunc is called when a section ends, with the number of lines
+// and bytes of its body
+type SectionEndFunc func(section string, lines, bytes int64)
+
+// Parser keeps the state of the parsing accross different files. It is
+// safe to call Parse concurrently, and to add handlers while parsing.
+// Every call to Parse uses the handlers found when each section starts.
 type Parser struct {
-	handlers map[string][]HandlerFunc
+	mu              sync.RWMutex
+	handlers        map[string][]SectionHandlerFunc
+	matchers        []matcher
+	defaults        []SectionHandlerFunc
+	statsHandlers   []StatsFunc
+	untimedStats    []StatsFunc
+	warningHandlers []WarningFunc
+	noteHandlers    []NoteFunc
+	startHandlers   []SectionStartFunc
+	endHandlers     []SectionEndFunc
+	middlewares     []Middleware
+
+	bufferSize   int
+	maxLineSize  int
+	truncateLong bool
+	strict       bool
+	stripCR      bool
+	multiline    bool
+	notes        bool
+	banner       *regexp.Regexp
+	bannerPrefix []byte
+	progress     ProgressFunc
+}
+
+// ProgressFunc is called while parsing with the number of bytes read
+// from the source and the number of sections found so far
+type ProgressFunc func(bytesRead, sectionsSeen int64)
+
+// progressInterval is how many bytes are read between the calls to the
+// progress function
+const progressInterval = 1 << 20
+
+// defaultBanner matches the banners supportconfig writes, such as
+// "#==[ Command ]====#"
+var defaultBanner = regexp.MustCompile(`#==\[ (.*?) \]=+`)
+
+// Option changes how a Parser works
+type Option func(p *Parser)
+
+// WithBufferSize sets the size of the read buffer. Lines longer than it
+// are still parsed, passed to the collectors in chunks.
+func WithBufferSize(size int) Option {
+	return func(p *Parser) {
+		p.bufferSize = size
+	}
+}
+
+// WithMaxLineSize limits the size of the lines of the source, not
+// counting the line break. Longer lines make the parsing fail with ErrLineTooLong,
+// unless WithTruncateLongLines is also given. Zero means no limit.
+func WithMaxLineSize(size int) Option {
+	return func(p *Parser) {
+		p.maxLineSize = size
+	}
+}
+
+// WithTruncateLongLines makes lines longer than the maximum line size to
+// be cut, keeping their line break, and reported as warnings instead of
+// failing the parsing
+func WithTruncateLongLines() Option {
+	return func(p *Parser) {
+		p.truncateLong = true
+	}
+}
+
+// WithStrictBanners makes lines that start like a banner but aren't a
+// valid one fail the parsing with ErrMalformedBanner, instead of being
+// reported as warnings and kept as content
+func WithStrictBanners() Option {
+	return func(p *Parser) {
+		p.strict = true
+	}
+}
+
+// WithStripCR makes the CR of CRLF line breaks to be dropped, so that
+// headers and bodies of sources converted to DOS line endings are read
+// as the original ones
+func WithStripCR() Option {
+	return func(p *Parser) {
+		p.stripCR = true
+	}
+}
+
+// WithMultilineHeaders makes the comment lines following the header of a
+// section part of it, instead of its body, so that handlers get them all
+// in Section.Header. Configuration File and Log File sections are left
+// out, as the files they have often start with comments.
+func WithMultilineHeaders() Option {
+	return func(p *Parser) {
+		p.multiline = true
+	}
+}
+
+// WithNotes makes the "# Note:" lines supportconfig adds after the headers
+// of sections to be passed to the functions added with HandleNote instead
+// of the collectors. The section stats still count them, as found in the
+// source. Only the lines before the first line of the body are taken as
+// notes, so that the comments of the files are kept.
+func WithNotes() Option {
+	return func(p *Parser) {
+		p.notes = true
+	}
+}
+
+// WithBanner sets the regular expression matching the lines that start
+// sections, with the section name as its first subexpression. Lines not
+// starting with its literal prefix are never checked against it. The
+// lines are matched without their line break, so re can be anchored with
+// $.
+//
+// WithBanner panics when re has no subexpression for the section name.
+func WithBanner(re *regexp.Regexp) Option {
+	if re.NumSubexp() < 1 {
+		panic("supportconfig: banner regexp has no subexpression for the section name: " + re.String())
+	}
+	return func(p *Parser) {
+		p.banner = re
+	}
+}
+
+// WithProgress sets a function to be called every time a megabyte of the
+// source is read, and once more when the parsing ends successfully
+func WithProgress(fn ProgressFunc) Option {
+	return func(p *Parser) {
+		p.progress = fn
+	}
+}
+
+// WithMiddleware adds middlewares as Use does, for the parsers made by
+// others, such as the one of the Splitter
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(p *Parser) {
+		p.Use(middlewares...)
+	}
 }
 
 // NewParser initialiazes a new Parser
-func NewParser() *Parser {
-	parser := &Parser{handlers: make(map[string][]HandlerFunc)}
+func NewParser(opts ...Option) *Parser {
+	parser := &Parser{
+		handlers:   make(map[string][]SectionHandlerFunc),
+		bufferSize: defaultBufferSize,
+		banner:     defaultBanner,
+	}
+	for _, opt := range opts {
+		opt(parser)
+	}
+	prefix, _ := parser.banner.LiteralPrefix()
+	parser.bannerPrefix = []byte(prefix)
 	return parser
 }
 
@@ -44,67 +455,705 @@ func ScanLinesIgnoreCR(data []byte, atEOF bool) (advance int, token []byte, err
 	return 0, nil, nil
 }
 
+// collector is a writer returned by a handler, along with the position
+// of the handler in the list of handlers for the section
+type collector struct {
+	io.WriteCloser
+	handler int
+}
+
+// collectorError is a failure writing to or closing a collector
+type collectorError struct {
+	destination string
+	err         error
+}
+
+func (e *collectorError) Error() string {
+	return e.err.Error()
+}
+
+// destination returns the name of what a collector writes to, when it
+// has a Name method
+func destination(w io.Writer) string {
+	if named, ok := w.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+// sectionState keeps the state of the section being parsed
+type sectionState struct {
+	banner     string
+	offset     int64
+	line       int64
+	stats      SectionStats
+	started    bool
+	timed      bool
+	begin      time.Time
+	collectors []collector
+
+	// headed is set when the first header line was read and more might
+	// follow
+	headed  bool
+	headers []string
+
+	// header backs Section.Header, reused across sections
+	header []string
+
+	// inBody is set once a line of the body that is not a note of
+	// supportconfig was read
+	inBody bool
+}
+
+// reset prepares the state to be reused for a new section, whose banner
+// starts at offset after line lines
+func (s *sectionState) reset(name, banner string, offset, line int64) {
+	*s = sectionState{banner: banner, offset: offset, line: line, stats: SectionStats{Section: name}, collectors: s.collectors[:0], header: s.header[:0]}
+}
+
+// start calls the handlers once the header of the section is known.
+// Writes to the collectors are only timed when timed is set, as reading
+// the clock for every line is costly.
+func (s *sectionState) start(handlers []SectionHandlerFunc, timed bool) error {
+	s.started = true
+	s.timed = timed
+	if timed {
+		// the stats are handed to the caller, so the times of the
+		// handlers aren't reused across sections
+		s.begin = time.Now()
+		s.stats.Handlers = make([]time.Duration, len(handlers))
+	}
+	s.header = append(append(s.header[:0], s.stats.Header), s.headers...)
+	kind := KindOf(s.stats.Section)
+	section := Section{
+		Name:   s.stats.Section,
+		Kind:   kind,
+		Path:   sectionPath(kind, s.stats.Header),
+		Banner: s.banner,
+		Header: s.header,
+	}
+	for i, handler := range handlers {
+		var begin time.Time
+		if timed {
+			begin = time.Now()
+		}
+		w, err := handler(section)
+		if timed {
+			s.stats.Handlers[i] += time.Since(begin)
+		}
+		if err != nil {
+			if err == SkipFile {
+				continue
+			}
+			return err
+		} else if w != nil {
+			s.collectors = append(s.collectors, collector{WriteCloser: w, handler: i})
+		}
+	}
+	return nil
+}
+
+func (s *sectionState) name() string {
+	if s == nil {
+		return ""
+	}
+	return s.stats.Section
+}
+
+// write passes a chunk of a body line to the collectors, including its
+// line break when there is one. newLine tells whether the chunk starts a
+// line. If any of the collectors fails, all of them are closed.
+func (s *sectionState) write(line []byte, newLine bool) error {
+	s.stats.Bytes += int64(len(line))
+	if newLine {
+		s.stats.Lines++
+	}
+	if !s.timed {
+		for _, c := range s.collectors {
+			if _, err := c.Write(line); err != nil {
+				s.close()
+				return &collectorError{destination: destination(c.WriteCloser), err: err}
+			}
+		}
+		return nil
+	}
+	// the clock is read once per collector, as this runs for every line
+	begin := time.Now()
+	for _, c := range s.collectors {
+		_, err := c.Write(line)
+		end := time.Now()
+		s.stats.Handlers[c.handler] += end.Sub(begin)
+		begin = end
+		if err != nil {
+			s.close()
+			return &collectorError{destination: destination(c.WriteCloser), err: err}
+		}
+	}
+	return nil
+}
+
+// close closes all the collectors, returning the first error found
+func (s *sectionState) close() error {
+	var first error
+	if s == nil {
+		return nil
+	}
+	for _, c := range s.collectors {
+		var begin time.Time
+		if s.timed {
+			begin = time.Now()
+		}
+		err := c.Close()
+		if s.timed {
+			s.stats.Handlers[c.handler] += time.Since(begin)
+		}
+		if err != nil && first == nil {
+			first = &collectorError{destination: destination(c.WriteCloser), err: err}
+		}
+	}
+	for i := range s.collectors {
+		s.collectors[i] = collector{}
+	}
+	s.collectors = s.collectors[:0]
+	return first
+}
+
+// matchBanner returns the position of the section name in a banner line
+func (p *Parser) matchBanner(line []byte) (begin, end int, found bool) {
+	if p.banner != defaultBanner {
+		match := p.banner.FindSubmatchIndex(bytes.TrimRight(line, "\r\n"))
+		if len(match) < 4 || match[2] < 0 {
+			return 0, 0, false
+		}
+		return match[2], match[3], true
+	}
+	// the same as the default banner regexp, which is too slow for the
+	// amount of sections in a bundle
+	begin = len(p.bannerPrefix)
+	i := bytes.Index(line[begin:], []byte(" ]="))
+	if i < 0 || bytes.IndexByte(line[begin:begin+i], '\n') >= 0 {
+		return 0, 0, false
+	}
+	return begin, begin + i, true
+}
+
+// maxInterned is how many strings an interner keeps
+const maxInterned = 256
+
+// interner avoids allocating the same strings again and again
+type interner map[string]string
+
+func (in interner) intern(b []byte) string {
+	if s, found := in[string(b)]; found {
+		return s
+	}
+	s := string(b)
+	if len(in) < maxInterned {
+		in[s] = s
+	}
+	return s
+}
+
 // Parse starts reading the source and triggers the events when sections
 // are matched.
 func (p *Parser) Parse(source io.Reader) error {
-	var section, afterSection string
-	var collectors []io.WriteCloser
-
-	re := regexp.MustCompile(`#==\[ (.*?) \]=+`)
-	scanner := bufio.NewScanner(source)
-	scanner.Split(ScanLinesIgnoreCR)
-
-section:
-	for _, collector := range collectors {
-		collector.Close()
-	}
-	collectors = nil
-	afterSection = ""
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if bytes.HasPrefix(line, []byte("#==[ ")) {
-			found := re.FindSubmatchIndex(line)
-			if len(found) > 0 {
-				begin, end := found[2], found[3]
-				section = string(line[begin:end])
-				goto section
-			}
-		} else if section != "" {
-			if afterSection == "" {
-				afterSection = string(line)
-				collectors = make([]io.WriteCloser, 0)
-				for _, handler := range p.handlers[section] {
-					if collector, err := handler(section, afterSection); err != nil {
-						if err == SkipFile {
-							continue
-
-						}
-						return err
-					} else if collector != nil {
-						collectors = append(collectors, collector)
-					}
+	return p.ParseContext(context.Background(), source)
+}
+
+// ParseContext works as Parse but stops, closing the collectors of the
+// current section, as soon as the context is done. The context is checked
+// between lines, so a read blocked in the source is not interrupted.
+//
+// Errors from handlers and from reading the source are returned as
+// *ParseError, while the context error is returned as is.
+func (p *Parser) ParseContext(ctx context.Context, source io.Reader) error {
+	return p.ParseFrom(ctx, source, Checkpoint{})
+}
+
+// ParseFrom works as ParseContext for a source positioned at the offset
+// of a checkpoint, usually the one of a ParseError, so that a source read
+// from a flaky stream can be resumed without reading it all again.
+//
+// The checkpoint of a failure inside a section is at its banner, so the
+// section is parsed again as a whole: its handlers and start functions
+// are called again and their collectors get all of its body.
+func (p *Parser) ParseFrom(ctx context.Context, source io.Reader, from Checkpoint) error {
+	var state *sectionState
+	lineno, offset, lineOffset := from.Line, from.Offset, from.Offset
+
+	// the section state and the names of the sections, which repeat
+	// a lot, are reused
+	var current sectionState
+	sections, reported := from.Sections, from.Offset
+
+	fail := func(err error) error {
+		state.close()
+		perr := &ParseError{Line: lineno, Offset: lineOffset, Section: state.name(), Err: err}
+		perr.Checkpoint = Checkpoint{Offset: lineOffset, Line: lineno - 1, Sections: sections}
+		if state != nil {
+			// the section is parsed again from its banner, as its
+			// collectors can't be resumed halfway
+			perr.Checkpoint = Checkpoint{Offset: state.offset, Line: state.line, Sections: sections - 1}
+		}
+		if cerr, ok := err.(*collectorError); ok {
+			perr.Destination = cerr.destination
+			perr.Err = cerr.err
+		}
+		return perr
+	}
+
+	names := make(interner)
+	var reader *bufio.Reader
+	if p.bufferSize == defaultBufferSize {
+		reader = readerPool.Get().(*bufio.Reader)
+		reader.Reset(source)
+		defer func() {
+			reader.Reset(nil)
+			readerPool.Put(reader)
+		}()
+	} else {
+		reader = bufio.NewReaderSize(source, p.bufferSize)
+	}
+	done := ctx.Done()
+
+	// lines longer than the buffer are read in chunks, continued tells
+	// whether the last chunk read didn't finish its line
+	var header, banner, note []byte
+	var lineSize int
+	continued, assembling, inNote := false, false, false
+
+	for {
+		if done != nil {
+			select {
+			case <-done:
+				state.close()
+				return ctx.Err()
+			default:
+			}
+		}
+		chunk, err := reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			if !continued {
+				lineno++
+				lineOffset = offset
+			}
+			return fail(err)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		newLine := !continued
+		if newLine {
+			lineno++
+			lineOffset = offset
+			lineSize = 0
+		}
+		offset += int64(len(chunk))
+		if p.progress != nil && offset-reported >= progressInterval {
+			p.progress(offset, sections)
+			reported = offset
+		}
+		continued = err == bufio.ErrBufferFull
+
+		// banners are matched against whole lines, so a line looking
+		// like one is put together before going on
+		if assembling || (newLine && continued && bytes.HasPrefix(chunk, p.bannerPrefix)) {
+			if !assembling {
+				banner = banner[:0]
+				assembling = true
+			}
+			banner = append(banner, chunk...)
+			if continued && len(banner) < maxBannerSize {
+				continue
+			}
+			chunk = banner
+			newLine = true
+			assembling = false
+		}
+
+		if newLine && !continued && bytes.HasPrefix(chunk, p.bannerPrefix) {
+			if begin, end, found := p.matchBanner(chunk); found {
+				if err := p.endSection(state, lineno); err != nil {
+					return fail(err)
+				}
+				current.reset(names.intern(chunk[begin:end]), names.intern(bytes.TrimRight(chunk, "\r\n")), lineOffset, lineno-1)
+				sections++
+				state = &current
+				continue
+			}
+			if len(p.bannerPrefix) > 0 {
+				if p.strict {
+					return fail(ErrMalformedBanner)
+				}
+				// not a banner after all, keep it as content
+				p.warn(Warning{Line: lineno, Section: state.name(), Message: "malformed section banner"})
+			}
+		}
+		size := len(chunk)
+		if p.stripCR {
+			if continued && bytes.HasSuffix(chunk, []byte("\r")) {
+				// a CR ending a chunk is only part of a line break when
+				// the next one starts with the LF; peeking may overwrite
+				// the read buffer, so the chunk is copied first
+				chunk = append([]byte(nil), chunk...)
+				if next, _ := reader.Peek(1); len(next) == 1 && next[0] == '\n' {
+					chunk = chunk[:len(chunk)-1]
 				}
-			} else {
-				for _, collector := range collectors {
-					collector.Write(line)
-					collector.Write([]byte("\n"))
+			} else if bytes.HasSuffix(chunk, []byte("\r\n")) {
+				chunk[len(chunk)-2] = '\n'
+				chunk = chunk[:len(chunk)-1]
+			}
+		}
+		// the line size is measured after stripping, as that is what
+		// reaches the collectors
+		lineSize += len(chunk)
+		if p.maxLineSize > 0 {
+			content := lineSize
+			if bytes.HasSuffix(chunk, []byte("\n")) {
+				content--
+			}
+			if content > p.maxLineSize {
+				if !p.truncateLong {
+					return fail(ErrLineTooLong)
+				}
+				previous := lineSize - len(chunk)
+				if previous <= p.maxLineSize {
+					p.warn(Warning{Line: lineno, Section: state.name(), Message: "line truncated"})
 				}
+				chunk = truncate(chunk, p.maxLineSize-previous)
+			}
+		}
+
+		if state == nil {
+			continue
+		}
+		if state.headed && !state.started && len(header) == 0 && !bytes.HasPrefix(chunk, []byte("#")) {
+			// the header ends at the first line that isn't a comment
+			if err := p.startSection(state); err != nil {
+				return fail(err)
 			}
 		}
+		if !state.started {
+			header = append(header, chunk...)
+			if continued {
+				continue
+			}
+			line := string(bytes.TrimSuffix(header, []byte("\n")))
+			state.stats.Offset = offset
+			header = header[:0]
+			if state.headed {
+				state.headers = append(state.headers, line)
+				continue
+			}
+			state.stats.Header = line
+			if p.multiline && !embedsFile(state.stats.Section) {
+				state.headed = true
+				continue
+			}
+			if err := p.startSection(state); err != nil {
+				return fail(err)
+			}
+		} else if p.notes && (inNote || newLine && !state.inBody && bytes.HasPrefix(chunk, notePrefix)) {
+			state.stats.Bytes += int64(size)
+			if newLine {
+				state.stats.Lines++
+			}
+			note = append(note, chunk...)
+			inNote = continued
+			if !inNote {
+				text := bytes.TrimSpace(bytes.TrimPrefix(note, notePrefix))
+				p.note(SectionNote{Line: lineno, Section: state.stats.Section, Header: state.stats.Header, Text: string(text)})
+				note = note[:0]
+			}
+		} else {
+			state.inBody = true
+			if err := state.write(chunk, newLine); err != nil {
+				return fail(err)
+			}
+			// the body size is the one found in the source, even if
+			// the line was truncated
+			state.stats.Bytes += int64(size - len(chunk))
+		}
 	}
-	if len(collectors) > 0 {
-		goto section
+	lineno++
+	lineOffset = offset
+	if err := p.endSection(state, lineno); err != nil {
+		return fail(err)
 	}
+	if p.progress != nil {
+		p.progress(offset, sections)
+	}
+	return nil
+}
 
+// truncate cuts a chunk to the given size, not counting its line break,
+// which is kept
+func truncate(chunk []byte, size int) []byte {
+	if size < 0 {
+		size = 0
+	}
+	if !bytes.HasSuffix(chunk, []byte("\n")) {
+		return chunk[:size]
+	}
+	if size >= len(chunk)-1 {
+		return chunk
+	}
+	// the three-index slice makes append copy instead of overwriting the
+	// read buffer
+	return append(chunk[:size:size], '\n')
+}
+
+// endSection closes the collectors of a section and reports its stats.
+// lineno is the line where the next section starts.
+func (p *Parser) endSection(state *sectionState, lineno int64) error {
+	if state == nil {
+		return nil
+	}
+	if state.headed && !state.started {
+		if err := p.startSection(state); err != nil {
+			return err
+		}
+	}
+	if !state.started {
+		p.warn(Warning{Line: lineno - 1, Section: state.name(), Message: "section has no header"})
+		p.sectionStarted(state)
+	}
+	if err := state.close(); err != nil {
+		return err
+	}
+	if state.timed {
+		state.stats.Elapsed = time.Since(state.begin)
+	}
+	p.mu.RLock()
+	endHandlers, statsHandlers := p.endHandlers, p.statsHandlers
+	untimedStats := p.untimedStats
+	p.mu.RUnlock()
+	for _, fn := range endHandlers {
+		fn(state.stats.Section, state.stats.Lines, state.stats.Bytes)
+	}
+	for _, fn := range untimedStats {
+		if err := fn(state.stats); err != nil {
+			return err
+		}
+	}
+	for _, fn := range statsHandlers {
+		if err := fn(state.stats); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // HandleSection adds a handler to a given slice of handlers for the
 // section found
 func (p *Parser) HandleSection(section string, handler HandlerFunc) {
+	p.HandleSectionFunc(section, handler.sectionHandler())
+}
+
+// HandleSectionFunc works as HandleSection, for handlers that need the
+// raw banner and header lines
+func (p *Parser) HandleSectionFunc(section string, handler SectionHandlerFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.handlers[section] = append(p.handlers[section], handler)
 }
 
+func (p *Parser) hasStatsHandlers() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.statsHandlers) > 0
+}
+
+// startSection reports the start of a section and calls its handlers,
+// once its header was read
+func (p *Parser) startSection(state *sectionState) error {
+	p.sectionStarted(state)
+	return state.start(p.sectionHandlers(state.stats.Section), p.hasStatsHandlers())
+}
+
+func (p *Parser) sectionStarted(state *sectionState) {
+	p.mu.RLock()
+	startHandlers := p.startHandlers
+	p.mu.RUnlock()
+	for _, fn := range startHandlers {
+		fn(state.stats.Section, state.stats.Header)
+	}
+}
+
+// OnSectionStart adds a function to be called when a section starts.
+// Sections without a header are reported with an empty one when they
+// end, so that every section gets both calls.
+func (p *Parser) OnSectionStart(fn SectionStartFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.startHandlers = append(p.startHandlers, fn)
+}
+
+// OnSectionEnd adds a function to be called when a section ends, after
+// the collectors of the section were closed
+func (p *Parser) OnSectionEnd(fn SectionEndFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.endHandlers = append(p.endHandlers, fn)
+}
+
+func (p *Parser) warn(w Warning) {
+	p.mu.RLock()
+	warningHandlers := p.warningHandlers
+	p.mu.RUnlock()
+	for _, fn := range warningHandlers {
+		fn(w)
+	}
+}
+
+func (p *Parser) note(n SectionNote) {
+	p.mu.RLock()
+	noteHandlers := p.noteHandlers
+	p.mu.RUnlock()
+	for _, fn := range noteHandlers {
+		fn(n)
+	}
+}
+
+// HandleNote adds a function to be called for every note found while
+// parsing with WithNotes
+func (p *Parser) HandleNote(fn NoteFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.noteHandlers = append(p.noteHandlers, fn)
+}
+
+// HandleWarning adds a function to be called for every warning found
+// while parsing
+func (p *Parser) HandleWarning(fn WarningFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.warningHandlers = append(p.warningHandlers, fn)
+}
+
+// HandleStats adds a function to be called with the stats of every
+// section once it ends
+func (p *Parser) HandleStats(fn StatsFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statsHandlers = append(p.statsHandlers, fn)
+}
+
+// handleUntimedStats works as HandleStats for functions that don't need
+// the time spent in the handlers, which is then only measured when
+// HandleStats is used as well
+func (p *Parser) handleUntimedStats(fn StatsFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.untimedStats = append(p.untimedStats, fn)
+}
+
+// matcher is a handler for all the sections whose name matches
+type matcher struct {
+	match   func(section string) bool
+	handler SectionHandlerFunc
+}
+
+// HandleSectionMatch adds a handler for all the sections whose name
+// matches the shell pattern, with the syntax of path.Match, such as
+// "Log File*"
+func (p *Parser) HandleSectionMatch(pattern string, handler HandlerFunc) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.matchers = append(p.matchers, matcher{
+		match: func(section string) bool {
+			matched, _ := path.Match(pattern, section)
+			return matched
+		},
+		handler: handler.sectionHandler(),
+	})
+	return nil
+}
+
+// HandleSectionRegexp adds a handler for all the sections whose name
+// matches the regular expression
+func (p *Parser) HandleSectionRegexp(re *regexp.Regexp, handler HandlerFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.matchers = append(p.matchers, matcher{match: re.MatchString, handler: handler.sectionHandler()})
+}
+
+// HandleDefault adds a handler for the sections that have no other
+// handler registered for them
+func (p *Parser) HandleDefault(handler HandlerFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.defaults = append(p.defaults, handler.sectionHandler())
+}
+
+// sectionHandlers returns the handlers for a section: first the ones
+// registered for its exact name, then the ones matching it, in the order
+// they were added. When there are none, the default handlers are used.
+func (p *Parser) sectionHandlers(section string) []SectionHandlerFunc {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	handlers := p.handlers[section]
+	if len(p.matchers) > 0 {
+		handlers = append([]SectionHandlerFunc(nil), handlers...)
+		for _, m := range p.matchers {
+			if m.match(section) {
+				handlers = append(handlers, m.handler)
+			}
+		}
+	}
+	if len(handlers) == 0 {
+		handlers = p.defaults
+	}
+	if len(p.middlewares) > 0 && len(handlers) > 0 {
+		wrapped := make([]SectionHandlerFunc, len(handlers))
+		for i, handler := range handlers {
+			wrapped[i] = wrap(handler, p.middlewares)
+		}
+		return wrapped
+	}
+	return handlers
+}
+
+// Middleware wraps a handler, so that what is common to many handlers,
+// such as redaction, metrics or size limits, can be written once
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Use adds middlewares wrapping all the handlers. The first middleware
+// added is the outermost one. The Splitter makes its own parser, which
+// gets middlewares with WithMiddleware in Config.Options.
+func (p *Parser) Use(middlewares ...Middleware) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.middlewares = append(p.middlewares, middlewares...)
+}
+
+// wrap applies the middlewares to a handler. Changes made by them to the
+// section name and header are seen by the handler.
+func wrap(handler SectionHandlerFunc, middlewares []Middleware) SectionHandlerFunc {
+	return func(section Section) (io.WriteCloser, error) {
+		next := HandlerFunc(func(name, header string) (io.WriteCloser, error) {
+			changed := section
+			changed.Name = name
+			changed.Header = []string{header}
+			if len(section.Header) > 1 {
+				changed.Header = append(changed.Header, section.Header[1:]...)
+			}
+			return handler(changed)
+		})
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		var header string
+		if len(section.Header) > 0 {
+			header = section.Header[0]
+		}
+		return next(section.Name, header)
+	}
+}
+
 // PathHandlerFunc says to the splitter what is the filename to be used
 // for a given path
 type PathHandlerFunc func(path string) (newpath string, err error)
@@ -119,15 +1168,274 @@ type Config struct {
 	// the destination path (later to be joined with the base
 	// directory)
 	PathHandler PathHandlerFunc
+
+	// Source is the name of the file being split, recorded in the list
+	// of extracted files so that they can be opened again
+	Source string
+
+	// RawNewlines keeps the blank lines supportconfig appends after
+	// every embedded file. By default they are dropped, so that the
+	// extracted files are identical to the original ones.
+	RawNewlines bool
+
+	// RateLimit is the maximum number of bytes per second written by
+	// the splitter, shared by all the extracted files. Zero means no
+	// limit.
+	RateLimit int64
+
+	// Options are passed to the parser used by the splitter
+	Options []Option
 }
 
 // Splitter has the state of the splitter
 type Splitter struct {
 	Config Config
+
+	limiter  *rateLimiter
+	created  map[string]bool
+	current  *File
+	counter  *lineEndingCounter
+	trimmer  *separatorTrimmer
+	files    []File
+	warnings []Warning
+}
+
+// File describes a file extracted by the splitter and where it came from
+type File struct {
+	// Origin is the path of the file as found in the source
+	Origin string
+
+	// Path is the destination path, relative to the base directory
+	Path string
+
+	// Source is the name of the source, as in Config.Source
+	Source string
+
+	// Offset and Size are the byte range of the file contents in the
+	// source
+	Offset int64
+	Size   int64
+
+	// LineEndings has the line breaks found in the file
+	LineEndings LineEndings
+
+	// LastLines is N for logs collected as "- Last N Lines", whose
+	// older lines might be missing, or zero when the file is whole
+	LastLines int64
+
+	// Notes are the "# Note:" lines supportconfig added before the
+	// contents of the file, when splitting with WithNotes. They are left
+	// out of the file, but not of its byte range in the source.
+	Notes []string
+}
+
+// Truncated says whether the file might have been cut by supportconfig,
+// which only collects the last lines of some logs
+func (f File) Truncated() bool {
+	return f.LastLines > 0
+}
+
+// LineEndings counts the line breaks of a file by kind
+type LineEndings struct {
+	LF   int64
+	CRLF int64
+}
+
+// String says whether the file uses LF, CRLF, a mix of both or has no
+// line breaks at all
+func (l LineEndings) String() string {
+	switch {
+	case l.LF > 0 && l.CRLF > 0:
+		return "mixed"
+	case l.CRLF > 0:
+		return "CRLF"
+	case l.LF > 0:
+		return "LF"
+	}
+	return "none"
+}
+
+// Mixed is true when the file has both LF and CRLF line breaks
+func (l LineEndings) Mixed() bool {
+	return l.LF > 0 && l.CRLF > 0
+}
+
+// lineEndingCounter counts the line breaks written through it
+type lineEndingCounter struct {
+	io.WriteCloser
+	endings LineEndings
+	cr      bool
+}
+
+func (l *lineEndingCounter) Name() string {
+	return destination(l.WriteCloser)
+}
+
+func (l *lineEndingCounter) Write(b []byte) (int, error) {
+	start := 0
+	for {
+		i := bytes.IndexByte(b[start:], '\n')
+		if i < 0 {
+			break
+		}
+		pos := start + i
+		if (pos > 0 && b[pos-1] == '\r') || (pos == 0 && l.cr) {
+			l.endings.CRLF++
+		} else {
+			l.endings.LF++
+		}
+		start = pos + 1
+	}
+	if len(b) > 0 {
+		l.cr = b[len(b)-1] == '\r'
+	}
+	return l.WriteCloser.Write(b)
+}
+
+// Range returns a reader for the file contents in the given source
+func (f File) Range(source io.ReaderAt) *io.SectionReader {
+	return io.NewSectionReader(source, f.Offset, f.Size)
+}
+
+type rangeReadCloser struct {
+	*io.SectionReader
+	io.Closer
+}
+
+// Open opens the source and returns a reader for the file contents in it
+func (f File) Open() (io.ReadCloser, error) {
+	if f.Source == "" {
+		return nil, fmt.Errorf("no source recorded for %s", f.Origin)
+	}
+	source, err := os.Open(f.Source)
+	if err != nil {
+		return nil, err
+	}
+	return &rangeReadCloser{SectionReader: f.Range(source), Closer: source}, nil
+}
+
+// Files returns the files extracted by the last call to Split, in the
+// order they appear in the source
+func (s *Splitter) Files() []File {
+	return s.files
+}
+
+// Warnings returns the warnings found by the last call to Split
+func (s *Splitter) Warnings() []Warning {
+	return s.warnings
+}
+
+func (s *Splitter) warn(w Warning) {
+	s.warnings = append(s.warnings, w)
+}
+
+// rateLimiter is a token bucket that allows a burst of up to one second
+// worth of bytes
+type rateLimiter struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: float64(rate), tokens: float64(rate)}
+}
+
+// wait blocks until n bytes can be written
+func (l *rateLimiter) wait(n int) {
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.rate {
+			l.tokens = l.rate
+		}
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens < 0 {
+		time.Sleep(time.Duration(-l.tokens / l.rate * float64(time.Second)))
+	}
+}
+
+type limitedWriter struct {
+	w       io.Writer
+	limiter *rateLimiter
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	w.limiter.wait(len(b))
+	return w.w.Write(b)
+}
+
+// separator is what supportconfig appends after every embedded file
+const separator = "\n\n"
+
+// separatorTrimmer holds back the trailing line breaks written to it, so
+// that the ones belonging to the separator are dropped when it is closed
+type separatorTrimmer struct {
+	io.WriteCloser
+	pending int
+}
+
+// trailing returns the number of bytes held back that belong to the
+// separator, which are only dropped when the whole separator was seen
+func (t *separatorTrimmer) trailing() int {
+	if t.pending == len(separator) {
+		return t.pending
+	}
+	return 0
+}
+
+func (t *separatorTrimmer) Close() error {
+	var err error
+	if n := t.pending - t.trailing(); n > 0 {
+		_, err = t.WriteCloser.Write(bytes.Repeat([]byte("\n"), n))
+	}
+	if cerr := t.WriteCloser.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (t *separatorTrimmer) Name() string {
+	return destination(t.WriteCloser)
+}
+
+func (t *separatorTrimmer) Write(b []byte) (int, error) {
+	var err error
+	n := len(b) - len(bytes.TrimRight(b, "\n"))
+	if n == len(b) {
+		t.pending += n
+		if extra := t.pending - len(separator); extra > 0 {
+			_, err = t.WriteCloser.Write(bytes.Repeat([]byte("\n"), extra))
+			t.pending = len(separator)
+		}
+		return len(b), err
+	}
+	if t.pending > 0 {
+		_, err = t.WriteCloser.Write(bytes.Repeat([]byte("\n"), t.pending))
+		if err != nil {
+			return 0, err
+		}
+	}
+	t.pending = n
+	if t.pending > len(separator) {
+		t.pending = len(separator)
+	}
+	_, err = t.WriteCloser.Write(b[:len(b)-t.pending])
+	return len(b), err
 }
 
 var SkipFile = fmt.Errorf("This file must be skipped")
 
+// ErrLineTooLong is returned when a line is longer than the limit set by
+// WithMaxLineSize
+var ErrLineTooLong = fmt.Errorf("line is longer than the maximum line size")
+
+// ErrMalformedBanner is returned in strict mode for lines that start like
+// a section banner but aren't a valid one
+var ErrMalformedBanner = fmt.Errorf("malformed section banner")
+
 const FileNotFound = "File not found"
 
 func afterlineToPath(afterline string) (string, error) {
@@ -142,12 +1450,30 @@ func afterlineToPath(afterline string) (string, error) {
 	return afterline, nil
 }
 
+// afterlineToLastLines returns N for headers of logs ending with
+// "- Last N Lines", or zero
+func afterlineToLastLines(afterline string) int64 {
+	if !strings.HasSuffix(afterline, " Lines") {
+		return 0
+	}
+	idx := strings.LastIndex(afterline, " - Last ")
+	if idx < 0 {
+		return 0
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(afterline[idx+len(" - Last "):], " Lines"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
 func (s *Splitter) handler(section, afterline string) (io.WriteCloser, error) {
 	var err error
 	var dest, origDest string
 
 	const prefix = "# "
 	if !strings.HasPrefix(afterline, prefix) {
+		s.warn(Warning{Section: section, Message: fmt.Sprintf("skipping section with unexpected header %q", afterline)})
 		return nil, SkipFile
 	}
 	origDest, err = afterlineToPath(afterline[len(prefix):])
@@ -156,6 +1482,7 @@ func (s *Splitter) handler(section, afterline string) (io.WriteCloser, error) {
 	}
 	origDest = utils.CleanPath(origDest)
 	if origDest == "" {
+		s.warn(Warning{Section: section, Message: fmt.Sprintf("skipping section without a path in header %q", afterline)})
 		return nil, SkipFile
 	}
 
@@ -175,6 +1502,12 @@ func (s *Splitter) handler(section, afterline string) (io.WriteCloser, error) {
 	path := filepath.Join(s.Config.Base, dest)
 	base := filepath.Dir(path)
 
+	if s.created[path] {
+		s.warn(Warning{Section: section, Message: fmt.Sprintf("%s is extracted more than once, keeping the last one", dest)})
+		s.forget(path)
+	}
+	s.created[path] = true
+
 	err = os.MkdirAll(base, os.ModePerm)
 	if err != nil {
 		return nil, err
@@ -184,11 +1517,48 @@ func (s *Splitter) handler(section, afterline string) (io.WriteCloser, error) {
 		return nil, err
 	}
 
-	writer := bufio.NewWriter(f)
+	var w io.Writer = f
+	if s.limiter != nil {
+		w = &limitedWriter{w: f, limiter: s.limiter}
+	}
+	writer := bufio.NewWriter(w)
 	nop := &NopWriteCloser{f: f}
 	nop.Writer = *writer
 
-	return nop, nil
+	s.current = &File{Origin: origDest, Path: dest, Source: s.Config.Source, LastLines: afterlineToLastLines(afterline)}
+	s.counter = &lineEndingCounter{WriteCloser: nop}
+	s.trimmer = nil
+	if !s.Config.RawNewlines {
+		s.trimmer = &separatorTrimmer{WriteCloser: s.counter}
+		return s.trimmer, nil
+	}
+
+	return s.counter, nil
+}
+
+// forget drops a file previously extracted to path from the list of files
+func (s *Splitter) forget(path string) {
+	for i, f := range s.files {
+		if filepath.Join(s.Config.Base, f.Path) == path {
+			s.files = append(s.files[:i], s.files[i+1:]...)
+			return
+		}
+	}
+}
+
+// stats records the byte range of the file extracted from the section
+func (s *Splitter) stats(stats SectionStats) error {
+	if s.current != nil {
+		s.current.Offset = stats.Offset
+		s.current.Size = stats.Bytes
+		if s.trimmer != nil {
+			s.current.Size -= int64(s.trimmer.trailing())
+		}
+		s.current.LineEndings = s.counter.endings
+		s.files = append(s.files, *s.current)
+		s.current = nil
+	}
+	return nil
 }
 
 type NopWriteCloser struct {
@@ -197,17 +1567,65 @@ type NopWriteCloser struct {
 }
 
 func (n *NopWriteCloser) Close() error {
-	n.Flush()
-	return n.f.Close()
+	err := n.Flush()
+	if cerr := n.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Name returns the name of the file being written
+func (n *NopWriteCloser) Name() string {
+	return n.f.Name()
 }
 
 // Runs the splitter for a reable source
 func (s *Splitter) Split(source io.Reader) error {
-	p := NewParser()
+	return s.SplitContext(context.Background(), source)
+}
+
+// SplitContext works as Split but stops as soon as the context is done
+func (s *Splitter) SplitContext(ctx context.Context, source io.Reader) error {
+	s.created = make(map[string]bool)
+	s.current = nil
+	s.files = nil
+	s.warnings = nil
+	return s.parser().ParseContext(ctx, source)
+}
+
+// SplitFrom resumes a failed call to Split from the checkpoint of its
+// ParseError, for a source positioned at the offset of the checkpoint.
+// The files extracted before the failure are kept, while the one being
+// extracted is extracted again.
+func (s *Splitter) SplitFrom(ctx context.Context, source io.Reader, from Checkpoint) error {
+	if s.created == nil {
+		s.created = make(map[string]bool)
+	}
+	if s.current != nil {
+		delete(s.created, filepath.Join(s.Config.Base, s.current.Path))
+		s.current = nil
+	}
+	return s.parser().ParseFrom(ctx, source, from)
+}
+
+// parser returns a parser extracting the files to the splitter
+func (s *Splitter) parser() *Parser {
+	p := NewParser(s.Config.Options...)
+
+	s.limiter = nil
+	if s.Config.RateLimit > 0 {
+		s.limiter = newRateLimiter(s.Config.RateLimit)
+	}
+	p.HandleWarning(s.warn)
+	p.handleUntimedStats(s.stats)
+	p.HandleNote(func(note SectionNote) {
+		if s.current != nil {
+			s.current.Notes = append(s.current.Notes, note.Text)
+		}
+	})
 
 	for _, name := range []string{"Configuration File", "Log File"} {
 		p.HandleSection(name, s.handler)
 	}
-
-	return p.Parse(source)
+	return p
 }
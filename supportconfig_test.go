@@ -2,11 +2,19 @@ package supportconfig_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/bhdn/go-supportconfig"
 	. "gopkg.in/check.v1"
@@ -27,8 +35,8 @@ func (cs *clientSuite) SetUpTest(c *C) {
 	//
 }
 
-// UglyExtraNewlines tags places that might need fixing when a proper parser
-// is in place
+// UglyExtraNewlines is the separator supportconfig appends after every
+// embedded file, which the parser passes along but the splitter drops
 const UglyExtraNewlines = "\n\n"
 
 var sampleCreateParser = `
@@ -154,6 +162,187 @@ func (cs *clientSuite) TestParseMultipleGroups(c *C) {
 	c.Assert(collector.String(), Equals, etcRelease+UglyExtraNewlines)
 }
 
+func (cs *clientSuite) TestParseStats(c *C) {
+	var stats []supportconfig.SectionStats
+	p := supportconfig.NewParser()
+	p.HandleSection("Configuration File", func(name, after string) (io.WriteCloser, error) {
+		return &NopWriteCloser{}, nil
+	})
+	p.HandleSection("Configuration File", func(name, after string) (io.WriteCloser, error) {
+		return nil, supportconfig.SkipFile
+	})
+	p.HandleStats(func(s supportconfig.SectionStats) error {
+		stats = append(stats, s)
+		return nil
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, IsNil)
+	c.Assert(len(stats), Equals, 4)
+	c.Assert(stats[0].Section, Equals, "Command")
+	c.Assert(stats[0].Header, Equals, "# /bin/date")
+	c.Assert(stats[0].Bytes, Equals, int64(len("Sun Apr  7 20:23:42 CEST 2019\n\n")))
+	c.Assert(stats[0].Lines, Equals, int64(2))
+	c.Assert(len(stats[0].Handlers), Equals, 0)
+	c.Assert(stats[2].Section, Equals, "Configuration File")
+	c.Assert(stats[2].Header, Equals, "# /etc/SuSE-release")
+	c.Assert(stats[2].Bytes, Equals, int64(len(etcRelease+UglyExtraNewlines)))
+	c.Assert(stats[2].Lines, Equals, int64(strings.Count(etcRelease+UglyExtraNewlines, "\n")))
+	c.Assert(len(stats[2].Handlers), Equals, 2)
+	c.Assert(stats[2].Elapsed >= stats[2].Handlers[0]+stats[2].Handlers[1], Equals, true)
+	c.Assert(stats[3].Section, Equals, "System")
+}
+
+func (cs *clientSuite) TestParseStatsBudget(c *C) {
+	sections := 0
+	p := supportconfig.NewParser()
+	p.HandleStats(func(s supportconfig.SectionStats) error {
+		sections++
+		if s.Bytes > 20 {
+			return fmt.Errorf("section %q is over budget", s.Header)
+		}
+		return nil
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, ErrorMatches, `line 15 \(offset 489\) in section "Command": section "# /bin/date" is over budget`)
+	c.Assert(sections, Equals, 1)
+}
+
+func (cs *clientSuite) TestParseSectionMatch(c *C) {
+	var got []string
+	p := supportconfig.NewParser()
+	err := p.HandleSectionMatch("Co*", func(name, after string) (io.WriteCloser, error) {
+		got = append(got, "glob "+name+" "+after)
+		return nil, nil
+	})
+	c.Assert(err, IsNil)
+	p.HandleSectionRegexp(regexp.MustCompile(`^(System|Command)$`), func(name, after string) (io.WriteCloser, error) {
+		got = append(got, "regexp "+name+" "+after)
+		return nil, nil
+	})
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		got = append(got, "exact "+name+" "+after)
+		return nil, nil
+	})
+	err = p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, IsNil)
+	c.Assert(got, DeepEquals, []string{
+		"exact Command # /bin/date",
+		"glob Command # /bin/date",
+		"regexp Command # /bin/date",
+		"exact Command # /bin/uname -a",
+		"glob Command # /bin/uname -a",
+		"regexp Command # /bin/uname -a",
+		"glob Configuration File # /etc/SuSE-release",
+		"regexp System # Virtualization",
+	})
+}
+
+func (cs *clientSuite) TestParseSectionMatchBadPattern(c *C) {
+	p := supportconfig.NewParser()
+	err := p.HandleSectionMatch("Log [File", func(name, after string) (io.WriteCloser, error) {
+		return nil, nil
+	})
+	c.Assert(err, ErrorMatches, "syntax error in pattern")
+}
+
+func (cs *clientSuite) TestParseDefault(c *C) {
+	var got []string
+	p := supportconfig.NewParser()
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		return nil, nil
+	})
+	err := p.HandleSectionMatch("Config*", func(name, after string) (io.WriteCloser, error) {
+		return nil, nil
+	})
+	c.Assert(err, IsNil)
+	collector := &NopWriteCloser{}
+	p.HandleDefault(func(name, after string) (io.WriteCloser, error) {
+		got = append(got, name+" "+after)
+		return collector, nil
+	})
+	err = p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, IsNil)
+	c.Assert(got, DeepEquals, []string{"System # Virtualization"})
+	c.Assert(strings.HasPrefix(collector.String(), "Hardware:      See hardware.txt\n"), Equals, true)
+}
+
+func (cs *clientSuite) TestParseSectionEvents(c *C) {
+	var events []string
+	p := supportconfig.NewParser()
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		events = append(events, "handler "+after)
+		return nil, nil
+	})
+	p.OnSectionStart(func(section, header string) {
+		events = append(events, fmt.Sprintf("start %s %q", section, header))
+	})
+	p.OnSectionEnd(func(section string, lines, bytes int64) {
+		events = append(events, fmt.Sprintf("end %s %d %d", section, lines, bytes))
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups + sampleOddities))
+	c.Assert(err, IsNil)
+	c.Assert(events, DeepEquals, []string{
+		`start Command "# /bin/date"`,
+		"handler # /bin/date",
+		"end Command 2 31",
+		`start Command "# /bin/uname -a"`,
+		"handler # /bin/uname -a",
+		"end Command 2 111",
+		`start Configuration File "# /etc/SuSE-release"`,
+		"end Configuration File 7 217",
+		`start System "# Virtualization"`,
+		"end System 5 175",
+		`start Configuration File "# /etc/hosts"`,
+		"end Configuration File 4 59",
+		`start Command ""`,
+		"end Command 0 0",
+		`start Configuration File "# /etc/hosts"`,
+		"end Configuration File 2 21",
+		`start Log File "/var/log/messages"`,
+		"end Log File 0 0",
+	})
+}
+
+type closeRecorder struct {
+	NopWriteCloser
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (cs *clientSuite) TestParseContextCancel(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	collector := &closeRecorder{}
+	sections := 0
+	p := supportconfig.NewParser()
+	p.HandleSection("Configuration File", func(name, after string) (io.WriteCloser, error) {
+		return collector, nil
+	})
+	p.OnSectionStart(func(section, header string) {
+		sections++
+		if section == "Configuration File" {
+			cancel()
+		}
+	})
+	err := p.ParseContext(ctx, strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(sections, Equals, 3)
+	c.Assert(collector.closed, Equals, true)
+	c.Assert(collector.String(), Equals, "")
+}
+
+func (cs *clientSuite) TestSplitContextCancel(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: c.MkDir()}}
+	err := splitter.SplitContext(ctx, strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(splitter.Files(), HasLen, 0)
+}
+
 var sampleMultipleFiles = `
 =============================================================================
                      Support Utilities - Supportconfig
@@ -218,7 +407,7 @@ func (cs *clientSuite) TestSplitterOneFile(c *C) {
 	c.Assert(gotPath[0], Equals, path)
 	b, err := ioutil.ReadFile(filepath.Join(base, path))
 	c.Assert(err, IsNil)
-	c.Assert(string(b), Equals, etcRelease+UglyExtraNewlines)
+	c.Assert(string(b), Equals, etcRelease)
 }
 
 const logEntry = `2011-01-07T11:11:01.111111+02:00 nodename03007.example.net invld>Apr  7 06:50:01 nodename03007 libvirtd[4975]: internal error: missing storage backend for network files using rbd protocol
@@ -247,7 +436,21 @@ func (cs *clientSuite) TestSplitterWithNote(c *C) {
 	c.Assert(gotPath[0], Equals, path)
 	b, err := ioutil.ReadFile(filepath.Join(base, path))
 	c.Assert(err, IsNil)
-	c.Assert(string(b), Equals, logEntry+UglyExtraNewlines)
+	c.Assert(string(b), Equals, logEntry)
+}
+
+func (cs *clientSuite) TestSplitterLastLines(c *C) {
+	config := supportconfig.Config{Base: c.MkDir()}
+	splitter := &supportconfig.Splitter{Config: config}
+	err := splitter.Split(strings.NewReader(sampleMultipleFiles + logEntryWithNote))
+	c.Assert(err, IsNil)
+
+	files := splitter.Files()
+	c.Assert(files, HasLen, 3)
+	c.Assert(files[0].Truncated(), Equals, false)
+	c.Assert(files[2].Origin, Equals, "/var/log/nodes/logname.log")
+	c.Assert(files[2].LastLines, Equals, int64(10000))
+	c.Assert(files[2].Truncated(), Equals, true)
 }
 
 const logEntryNotFound = `
@@ -275,6 +478,23 @@ func (cs *clientSuite) TestSplitterNotFound(c *C) {
 	c.Assert(err, ErrorMatches, ".*no such file or directory.*")
 }
 
+func (cs *clientSuite) TestSplitterEmptyFile(c *C) {
+	const empty = "#==[ Log File ]=====================================#\n# /var/log/empty.log - Last 0 Lines\n"
+	for _, source := range []string{
+		empty + "#==[ Command ]======================================#\n# /bin/date\ntoday\n",
+		empty,
+		strings.TrimSuffix(empty, "\n"),
+	} {
+		base := c.MkDir()
+		splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: base}}
+		c.Assert(splitter.Split(strings.NewReader(source)), IsNil)
+		b, err := ioutil.ReadFile(filepath.Join(base, "var/log/empty.log"))
+		c.Assert(err, IsNil)
+		c.Assert(b, HasLen, 0)
+		c.Assert(splitter.Files()[0].Size, Equals, int64(0))
+	}
+}
+
 const maliciousLogEntry = `
 #==[ Log File ]===============================#
 # /var/../../../../../.vimrc - Last 10000 Lines
@@ -298,7 +518,7 @@ func (cs *clientSuite) TestSplitterWithMaliciousPath(c *C) {
 	c.Assert(gotPath[0], Equals, path)
 	b, err := ioutil.ReadFile(filepath.Join(base, path))
 	c.Assert(err, IsNil)
-	c.Assert(string(b), Equals, logEntry+UglyExtraNewlines)
+	c.Assert(string(b), Equals, logEntry)
 }
 
 const ignoreLogFile = `
@@ -325,3 +545,972 @@ func (cs *clientSuite) TestIgnoreLogFile(c *C) {
 	_, err = ioutil.ReadFile(filepath.Join(base, path))
 	c.Assert(err, Not(IsNil))
 }
+
+func (cs *clientSuite) TestSplitterRateLimit(c *C) {
+	base := c.MkDir()
+	body := strings.Repeat(logEntry, 15)
+	source := `
+#==[ Log File ]===============================#
+# /var/log/messages
+` + body + UglyExtraNewlines
+	config := supportconfig.Config{Base: base, RateLimit: 2000}
+	splitter := &supportconfig.Splitter{Config: config}
+
+	begin := time.Now()
+	err := splitter.Split(strings.NewReader(source))
+	c.Assert(err, IsNil)
+	// the first 2000 bytes are a free burst, the rest is throttled
+	elapsed := time.Since(begin)
+	wait := time.Duration(float64(len(body)-2000) / 2000 * float64(time.Second))
+	c.Assert(elapsed >= wait*9/10, Equals, true, Commentf("took %s", elapsed))
+
+	b, err := ioutil.ReadFile(filepath.Join(base, "/var/log/messages"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, body)
+}
+
+const sampleOddities = `
+#==[ Configuration File ]===========================#
+# /etc/hosts
+127.0.0.1 localhost
+#==[ Configuration File
+::1 localhost
+
+#==[ Command ]======================================#
+#==[ Configuration File ]===========================#
+# /etc/hosts
+127.0.0.1 localhost
+
+#==[ Log File ]=====================================#
+/var/log/messages
+`
+
+func (cs *clientSuite) TestParseWarnings(c *C) {
+	var warnings []supportconfig.Warning
+	p := supportconfig.NewParser()
+	p.HandleWarning(func(w supportconfig.Warning) {
+		warnings = append(warnings, w)
+	})
+	err := p.Parse(strings.NewReader(sampleOddities))
+	c.Assert(err, IsNil)
+	c.Assert(warnings, DeepEquals, []supportconfig.Warning{
+		{Line: 5, Section: "Configuration File", Message: "malformed section banner"},
+		{Line: 8, Section: "Command", Message: "section has no header"},
+	})
+	c.Assert(warnings[0].String(), Equals, "line 5: malformed section banner")
+}
+
+func (cs *clientSuite) TestHandleSectionFunc(c *C) {
+	var sections []supportconfig.Section
+	p := supportconfig.NewParser()
+	p.HandleSectionFunc("Log File", func(section supportconfig.Section) (io.WriteCloser, error) {
+		sections = append(sections, section)
+		return nil, nil
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups + logEntryWithNote))
+	c.Assert(err, IsNil)
+	c.Assert(sections, DeepEquals, []supportconfig.Section{{
+		Name:   "Log File",
+		Kind:   supportconfig.KindLogFile,
+		Path:   "/var/log/nodes/logname.log",
+		Banner: "#==[ Log File ]===============================#",
+		Header: []string{"# /var/log/nodes/logname.log - Last 10000 Lines"},
+	}})
+}
+
+func (cs *clientSuite) TestParseStrictBanners(c *C) {
+	p := supportconfig.NewParser(supportconfig.WithStrictBanners())
+	err := p.Parse(strings.NewReader(sampleOddities))
+	c.Assert(errors.Is(err, supportconfig.ErrMalformedBanner), Equals, true)
+	perr, ok := err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(perr.Line, Equals, int64(5))
+	c.Assert(perr.Section, Equals, "Configuration File")
+
+	p = supportconfig.NewParser(supportconfig.WithStrictBanners())
+	c.Assert(p.Parse(strings.NewReader(sampleMultipleGroups)), IsNil)
+}
+
+func (cs *clientSuite) TestSplitterWarnings(c *C) {
+	base := c.MkDir()
+	config := supportconfig.Config{Base: base}
+	splitter := &supportconfig.Splitter{Config: config}
+
+	err := splitter.Split(strings.NewReader(sampleOddities))
+	c.Assert(err, IsNil)
+	c.Assert(splitter.Warnings(), DeepEquals, []supportconfig.Warning{
+		{Line: 5, Section: "Configuration File", Message: "malformed section banner"},
+		{Line: 8, Section: "Command", Message: "section has no header"},
+		{Section: "Configuration File", Message: "/etc/hosts is extracted more than once, keeping the last one"},
+		{Section: "Log File", Message: `skipping section with unexpected header "/var/log/messages"`},
+	})
+
+	err = splitter.Split(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, IsNil)
+	c.Assert(splitter.Warnings(), HasLen, 0)
+}
+
+func (cs *clientSuite) TestSplitterFiles(c *C) {
+	base := c.MkDir()
+	source := filepath.Join(c.MkDir(), "basic-environment.txt")
+	err := ioutil.WriteFile(source, []byte(sampleMultipleFiles), 0644)
+	c.Assert(err, IsNil)
+	f, err := os.Open(source)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	config := supportconfig.Config{Base: base, Source: source}
+	splitter := &supportconfig.Splitter{Config: config}
+	err = splitter.Split(f)
+	c.Assert(err, IsNil)
+
+	files := splitter.Files()
+	c.Assert(len(files), Equals, 2)
+	c.Assert(files[0].Origin, Equals, "/etc/SuSE-release")
+	c.Assert(files[0].Path, Equals, "/etc/SuSE-release")
+	c.Assert(files[0].Source, Equals, source)
+	c.Assert(files[0].Offset, Equals, int64(strings.Index(sampleMultipleFiles, etcRelease)))
+	c.Assert(files[0].Size, Equals, int64(len(etcRelease)))
+	c.Assert(files[1].Origin, Equals, "/etc/os-release")
+
+	for _, file := range files {
+		extracted, err := ioutil.ReadFile(filepath.Join(base, file.Path))
+		c.Assert(err, IsNil)
+		r, err := file.Open()
+		c.Assert(err, IsNil)
+		original, err := ioutil.ReadAll(r)
+		c.Assert(err, IsNil)
+		c.Assert(r.Close(), IsNil)
+		c.Assert(string(original), Equals, string(extracted))
+	}
+}
+
+func (cs *clientSuite) TestSplitterFilesCollision(c *C) {
+	config := supportconfig.Config{Base: c.MkDir()}
+	splitter := &supportconfig.Splitter{Config: config}
+	err := splitter.Split(strings.NewReader(sampleOddities))
+	c.Assert(err, IsNil)
+
+	files := splitter.Files()
+	c.Assert(len(files), Equals, 1)
+	c.Assert(files[0].Path, Equals, "/etc/hosts")
+	c.Assert(files[0].Offset, Equals, int64(strings.LastIndex(sampleOddities, "127.0.0.1")))
+	_, err = files[0].Open()
+	c.Assert(err, ErrorMatches, "no source recorded for /etc/hosts")
+}
+
+var binaryBody = "EDID\x00\xff\xff\xff\xff\xff\xff\x00\x10\xac\n" +
+	"invalid utf-8 \xc3\x28 \xa0\xa1\r\n" +
+	"\x00\x00\x00\n" +
+	"#==[ not a banner\n" +
+	"no final newline \xfe"
+
+var sampleBinary = `
+#==[ Command ]======================================#
+# /usr/bin/get-edid
+` + binaryBody
+
+func (cs *clientSuite) TestParseBinaryPassthrough(c *C) {
+	collector := &NopWriteCloser{}
+	p := supportconfig.NewParser()
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		return collector, nil
+	})
+	err := p.Parse(strings.NewReader(sampleBinary))
+	c.Assert(err, IsNil)
+	c.Assert(collector.Bytes(), DeepEquals, []byte(binaryBody))
+}
+
+func (cs *clientSuite) TestSplitterBinaryPassthrough(c *C) {
+	base := c.MkDir()
+	source := strings.Replace(sampleBinary, "Command", "Configuration File", 1)
+	splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: base}}
+	err := splitter.Split(strings.NewReader(source))
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(filepath.Join(base, "/usr/bin/get-edid"))
+	c.Assert(err, IsNil)
+	c.Assert(b, DeepEquals, []byte(binaryBody))
+	c.Assert(splitter.Files()[0].Size, Equals, int64(len(binaryBody)))
+}
+
+func (cs *clientSuite) TestParseLongLines(c *C) {
+	long := strings.Repeat("x", 200*1024)
+	body := long + "\n" + "short\n" + long
+	var lines, size int64
+	collector := &NopWriteCloser{}
+	p := supportconfig.NewParser()
+	p.HandleSection("Log File", func(name, after string) (io.WriteCloser, error) {
+		return collector, nil
+	})
+	p.OnSectionEnd(func(section string, l, b int64) {
+		lines, size = l, b
+	})
+	header := "# /var/log/" + long
+	err := p.Parse(strings.NewReader("#==[ Log File ]=====#\n" + header + "\n" + body))
+	c.Assert(err, IsNil)
+	c.Assert(collector.String() == body, Equals, true)
+	c.Assert(lines, Equals, int64(3))
+	c.Assert(size, Equals, int64(len(body)))
+}
+
+type brokenReader struct {
+	io.Reader
+}
+
+func (b *brokenReader) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (cs *clientSuite) TestParseReadError(c *C) {
+	p := supportconfig.NewParser()
+	err := p.Parse(&brokenReader{strings.NewReader(sampleBinary)})
+	c.Assert(errors.Is(err, io.ErrUnexpectedEOF), Equals, true)
+	perr, ok := err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	// the body has no final newline, so the error happens in its last line
+	c.Assert(perr.Line, Equals, int64(8))
+	c.Assert(perr.Offset, Equals, int64(strings.LastIndex(sampleBinary, "no final newline")))
+	c.Assert(perr.Section, Equals, "Command")
+}
+
+func (cs *clientSuite) TestParseErrorLocation(c *C) {
+	p := supportconfig.NewParser()
+	p.HandleSection("Configuration File", func(name, after string) (io.WriteCloser, error) {
+		return nil, os.ErrPermission
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, ErrorMatches, `line 20 \(offset 724\) in section "Configuration File": permission denied`)
+	perr, ok := err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(perr.Line, Equals, int64(20))
+	c.Assert(perr.Offset, Equals, int64(strings.Index(sampleMultipleGroups, "# /etc/SuSE-release")))
+	c.Assert(perr.Section, Equals, "Configuration File")
+	c.Assert(errors.Is(err, os.ErrPermission), Equals, true)
+}
+
+func (cs *clientSuite) TestSplitterRawNewlines(c *C) {
+	base := c.MkDir()
+	config := supportconfig.Config{Base: base, RawNewlines: true}
+	splitter := &supportconfig.Splitter{Config: config}
+
+	err := splitter.Split(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(filepath.Join(base, "/etc/SuSE-release"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, etcRelease+UglyExtraNewlines)
+	c.Assert(splitter.Files()[0].Size, Equals, int64(len(etcRelease+UglyExtraNewlines)))
+}
+
+func (cs *clientSuite) TestSplitterTrailingNewlines(c *C) {
+	for _, content := range []string{
+		"no final newline",
+		"one final newline\n",
+		"blank lines at the end\n\n\n",
+		"crlf\r\n",
+		"\n",
+		"",
+	} {
+		base := c.MkDir()
+		source := `
+#==[ Configuration File ]===========================#
+# /etc/sample.conf
+` + content + UglyExtraNewlines + `#==[ Command ]======================================#
+# /bin/date
+Sun Apr  7 20:23:42 CEST 2019
+`
+		splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: base}}
+		err := splitter.Split(strings.NewReader(source))
+		c.Assert(err, IsNil)
+
+		b, err := ioutil.ReadFile(filepath.Join(base, "/etc/sample.conf"))
+		c.Assert(err, IsNil)
+		c.Assert(string(b), Equals, content)
+		c.Assert(splitter.Files()[0].Size, Equals, int64(len(content)))
+	}
+}
+
+func (cs *clientSuite) TestSplitterWithoutSeparator(c *C) {
+	base := c.MkDir()
+	source := `
+#==[ Configuration File ]===========================#
+# /etc/short.conf
+one newline
+#==[ Configuration File ]===========================#
+# /etc/last.conf
+line1
+line2
+`
+	splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: base}}
+	err := splitter.Split(strings.NewReader(source))
+	c.Assert(err, IsNil)
+
+	// only a complete separator is dropped from the files
+	for path, content := range map[string]string{
+		"/etc/short.conf": "one newline\n",
+		"/etc/last.conf":  "line1\nline2\n",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(base, path))
+		c.Assert(err, IsNil)
+		c.Assert(string(b), Equals, content)
+	}
+	files := splitter.Files()
+	c.Assert(files, HasLen, 2)
+	c.Assert(files[0].Size, Equals, int64(len("one newline\n")))
+	c.Assert(files[1].Size, Equals, int64(len("line1\nline2\n")))
+}
+
+const sampleLineEndings = `
+#==[ Configuration File ]===========================#
+# /etc/unix.conf
+` + "one\ntwo\n" + UglyExtraNewlines + `#==[ Configuration File ]===========================#
+# /etc/dos.conf
+` + "one\r\ntwo\r\n" + UglyExtraNewlines + `#==[ Configuration File ]===========================#
+# /etc/mixed.conf
+` + "one\r\ntwo\nthree\r\n" + UglyExtraNewlines + `#==[ Configuration File ]===========================#
+# /etc/oneline.conf
+` + "single" + UglyExtraNewlines
+
+func (cs *clientSuite) TestSplitterLineEndings(c *C) {
+	splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: c.MkDir()}}
+	err := splitter.Split(strings.NewReader(sampleLineEndings))
+	c.Assert(err, IsNil)
+
+	files := splitter.Files()
+	c.Assert(len(files), Equals, 4)
+	c.Assert(files[0].LineEndings, Equals, supportconfig.LineEndings{LF: 2})
+	c.Assert(files[0].LineEndings.String(), Equals, "LF")
+	c.Assert(files[1].LineEndings, Equals, supportconfig.LineEndings{CRLF: 2})
+	c.Assert(files[1].LineEndings.String(), Equals, "CRLF")
+	c.Assert(files[2].LineEndings, Equals, supportconfig.LineEndings{LF: 1, CRLF: 2})
+	c.Assert(files[2].LineEndings.String(), Equals, "mixed")
+	c.Assert(files[2].LineEndings.Mixed(), Equals, true)
+	c.Assert(files[3].LineEndings.String(), Equals, "none")
+}
+
+type failingCollector struct {
+	NopWriteCloser
+	name string
+}
+
+func (f *failingCollector) Write(b []byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+func (f *failingCollector) Name() string {
+	return f.name
+}
+
+func (cs *clientSuite) TestParseWriteError(c *C) {
+	recorder := &closeRecorder{}
+	p := supportconfig.NewParser()
+	p.HandleSection("Configuration File", func(name, after string) (io.WriteCloser, error) {
+		return recorder, nil
+	})
+	p.HandleSection("Configuration File", func(name, after string) (io.WriteCloser, error) {
+		return &failingCollector{name: "release.out"}, nil
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, ErrorMatches, `line 21 \(offset 744\) in section "Configuration File", writing to release.out: short write`)
+	perr, ok := err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(perr.Destination, Equals, "release.out")
+	c.Assert(perr.Err, Equals, io.ErrShortWrite)
+	c.Assert(recorder.closed, Equals, true)
+}
+
+func (cs *clientSuite) TestSplitterWriteError(c *C) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		c.Skip("no /dev/full to simulate a full disk")
+	}
+	handler := func(path string) (string, error) {
+		return "full", nil
+	}
+	config := supportconfig.Config{Base: "/dev", PathHandler: handler}
+	splitter := &supportconfig.Splitter{Config: config}
+
+	err := splitter.Split(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, NotNil)
+	perr, ok := err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(perr.Section, Equals, "Configuration File")
+	c.Assert(perr.Destination, Equals, "/dev/full")
+	c.Assert(errors.Is(err, syscall.ENOSPC), Equals, true)
+}
+
+func (cs *clientSuite) TestParseConcurrent(c *C) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	headers := make(map[string]int)
+	p := supportconfig.NewParser()
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		headers[after]++
+		return &NopWriteCloser{}, nil
+	})
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- p.Parse(strings.NewReader(sampleMultipleGroups))
+		}()
+	}
+	p.HandleWarning(func(supportconfig.Warning) {})
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		c.Assert(err, IsNil)
+	}
+	c.Assert(headers["# /bin/date"], Equals, 8)
+}
+
+func (cs *clientSuite) TestParseStripCR(c *C) {
+	var headers []string
+	collector := &NopWriteCloser{}
+	source := strings.Replace(sampleMultipleFiles, "\n", "\r\n", -1)
+	p := supportconfig.NewParser(supportconfig.WithBufferSize(16), supportconfig.WithStripCR())
+	p.HandleSection("Configuration File", func(name, after string) (io.WriteCloser, error) {
+		headers = append(headers, after)
+		if after == "# /etc/os-release" {
+			return collector, nil
+		}
+		return nil, nil
+	})
+	c.Assert(p.Parse(strings.NewReader(source)), IsNil)
+	c.Assert(headers, DeepEquals, []string{"# /etc/SuSE-release", "# /etc/os-release"})
+	c.Assert(collector.String(), Equals, osRelease+UglyExtraNewlines)
+
+	// a lone CR is kept, even when split from the rest of the line
+	collector.Reset()
+	p = supportconfig.NewParser(supportconfig.WithBufferSize(16), supportconfig.WithStripCR())
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		return collector, nil
+	})
+	source = "#==[ Command ]======================================#\r\n# /bin/date\r\n0123456789abcde\rx\r\n"
+	c.Assert(p.Parse(strings.NewReader(source)), IsNil)
+	c.Assert(collector.String(), Equals, "0123456789abcde\rx\n")
+
+	// nor is it lost when it ends the source
+	collector.Reset()
+	source = "#==[ Command ]======================================#\r\n# /bin/date\r\n0123456789abcde\r"
+	c.Assert(p.Parse(strings.NewReader(source)), IsNil)
+	c.Assert(collector.String(), Equals, "0123456789abcde\r")
+}
+
+func (cs *clientSuite) TestParseStripCRMaxLineSize(c *C) {
+	collector := &NopWriteCloser{}
+	p := supportconfig.NewParser(supportconfig.WithBufferSize(16), supportconfig.WithStripCR(), supportconfig.WithMaxLineSize(15))
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		return collector, nil
+	})
+	// the stripped CR doesn't count toward the limit
+	source := "#==[ Command ]======================================#\r\n# /bin/date\r\n0123456789abcde\r\n"
+	c.Assert(p.Parse(strings.NewReader(source)), IsNil)
+	c.Assert(collector.String(), Equals, "0123456789abcde\n")
+
+	source = "#==[ Command ]======================================#\r\n# /bin/date\r\n0123456789abcdef\r\n"
+	err := p.Parse(strings.NewReader(source))
+	c.Assert(errors.Is(err, supportconfig.ErrLineTooLong), Equals, true)
+}
+
+func (cs *clientSuite) TestParseBanner(c *C) {
+	var sections []string
+	p := supportconfig.NewParser(supportconfig.WithBanner(regexp.MustCompile(`^--- (\w+) ---`)))
+	p.HandleDefault(func(name, after string) (io.WriteCloser, error) {
+		sections = append(sections, name+": "+after)
+		return nil, nil
+	})
+	source := "--- uptime ---\n# /usr/bin/uptime\n 10:00 up 1 day\n--- bad\n--- date ---\n# /bin/date\ntoday\n"
+	c.Assert(p.Parse(strings.NewReader(source+sampleMultipleGroups)), IsNil)
+	c.Assert(sections, DeepEquals, []string{"uptime: # /usr/bin/uptime", "date: # /bin/date"})
+}
+
+func (cs *clientSuite) TestBannerWithoutName(c *C) {
+	c.Assert(func() { supportconfig.WithBanner(regexp.MustCompile(`^--- \w+ ---`)) }, PanicMatches, ".*no subexpression.*")
+}
+
+func (cs *clientSuite) TestSplitterBanner(c *C) {
+	config := supportconfig.Config{
+		Base:    c.MkDir(),
+		Options: []supportconfig.Option{supportconfig.WithBanner(regexp.MustCompile(`^--- ([\w ]+) ---$`))},
+	}
+	splitter := &supportconfig.Splitter{Config: config}
+	source := "--- Configuration File ---\n# /etc/hostname\nnode1\n\n--- Command ---\n# /bin/date\ntoday\n"
+	c.Assert(splitter.Split(strings.NewReader(source)), IsNil)
+	b, err := ioutil.ReadFile(filepath.Join(config.Base, "etc", "hostname"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "node1")
+}
+
+func (cs *clientSuite) TestParseProgress(c *C) {
+	type progress struct{ bytes, sections int64 }
+	var calls []progress
+	source := bytes.Repeat([]byte(sampleMultipleGroups), 1000)
+	p := supportconfig.NewParser(supportconfig.WithProgress(func(bytesRead, sectionsSeen int64) {
+		calls = append(calls, progress{bytesRead, sectionsSeen})
+	}))
+	c.Assert(p.Parse(bytes.NewReader(source)), IsNil)
+	c.Assert(len(calls) > 1, Equals, true)
+	for i := 1; i < len(calls); i++ {
+		c.Assert(calls[i].bytes > calls[i-1].bytes, Equals, true)
+		c.Assert(calls[i].sections >= calls[i-1].sections, Equals, true)
+	}
+	sections := int64(strings.Count(sampleMultipleGroups, "#==[ ")) * 1000
+	c.Assert(calls[len(calls)-1], Equals, progress{int64(len(source)), sections})
+}
+
+func (cs *clientSuite) TestSplitterProgress(c *C) {
+	var last int64
+	config := supportconfig.Config{
+		Base: c.MkDir(),
+		Options: []supportconfig.Option{supportconfig.WithProgress(func(bytesRead, sectionsSeen int64) {
+			last = bytesRead
+		})},
+	}
+	splitter := &supportconfig.Splitter{Config: config}
+	c.Assert(splitter.Split(strings.NewReader(sampleMultipleFiles)), IsNil)
+	c.Assert(last, Equals, int64(len(sampleMultipleFiles)))
+}
+
+const sampleMultilineHeaders = `#==[ Command ]======================================#
+# /usr/bin/zypper --non-interactive lr -u
+# Note: the repositories of the update server only
+#
+Repository priorities are without effect.
+# | Alias | Name
+1 | SLES  | SLES
+
+#==[ Configuration File ]===========================#
+# /etc/fstab
+# the root filesystem
+UUID=1234 / btrfs defaults 0 0
+
+#==[ Command ]======================================#
+# /bin/df -h
+# Note: no output
+`
+
+func (cs *clientSuite) TestParseMultilineHeaders(c *C) {
+	var headers [][]string
+	bodies := make(map[string]*NopWriteCloser)
+	p := supportconfig.NewParser(supportconfig.WithMultilineHeaders())
+	for _, name := range []string{"Command", "Configuration File"} {
+		p.HandleSectionFunc(name, func(section supportconfig.Section) (io.WriteCloser, error) {
+			// the header is reused for the next section
+			headers = append(headers, append([]string(nil), section.Header...))
+			bodies[section.Header[0]] = &NopWriteCloser{}
+			return bodies[section.Header[0]], nil
+		})
+	}
+	var stats []supportconfig.SectionStats
+	p.HandleStats(func(s supportconfig.SectionStats) error {
+		stats = append(stats, s)
+		return nil
+	})
+	c.Assert(p.Parse(strings.NewReader(sampleMultilineHeaders)), IsNil)
+	c.Assert(headers, DeepEquals, [][]string{
+		{"# /usr/bin/zypper --non-interactive lr -u", "# Note: the repositories of the update server only", "#"},
+		{"# /etc/fstab"},
+		{"# /bin/df -h", "# Note: no output"},
+	})
+	body := "Repository priorities are without effect.\n# | Alias | Name\n1 | SLES  | SLES\n\n"
+	c.Assert(bodies["# /usr/bin/zypper --non-interactive lr -u"].String(), Equals, body)
+	c.Assert(bodies["# /etc/fstab"].String(), Equals, "# the root filesystem\nUUID=1234 / btrfs defaults 0 0\n\n")
+	c.Assert(bodies["# /bin/df -h"].String(), Equals, "")
+	c.Assert(stats[0].Offset, Equals, int64(strings.Index(sampleMultilineHeaders, "Repository")))
+	c.Assert(stats[0].Lines, Equals, int64(4))
+
+	p = supportconfig.NewParser()
+	p.HandleSectionFunc("Command", func(section supportconfig.Section) (io.WriteCloser, error) {
+		c.Assert(section.Header, HasLen, 1)
+		return nil, nil
+	})
+	c.Assert(p.Parse(strings.NewReader(sampleMultilineHeaders)), IsNil)
+}
+
+const sampleNotes = `#==[ Command ]======================================#
+# /bin/rpm -qa
+# Note: the list is too big, only its first lines are included
+# Note: sorted by name
+aaa_base-15.1
+# Note: not a note of supportconfig
+bash-4.4
+
+#==[ Configuration File ]===========================#
+# /etc/sample.conf
+# Note: the file was changed after installation
+option = 1
+# Note: a comment of the file
+other = 2
+` + UglyExtraNewlines
+
+func (cs *clientSuite) TestParseNotes(c *C) {
+	var notes []supportconfig.SectionNote
+	command := &NopWriteCloser{}
+	p := supportconfig.NewParser(supportconfig.WithNotes(), supportconfig.WithBufferSize(16))
+	p.HandleNote(func(note supportconfig.SectionNote) {
+		notes = append(notes, note)
+	})
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		return command, nil
+	})
+	var stats []supportconfig.SectionStats
+	p.HandleStats(func(s supportconfig.SectionStats) error {
+		stats = append(stats, s)
+		return nil
+	})
+	c.Assert(p.Parse(strings.NewReader(sampleNotes)), IsNil)
+	// only the lines before the body are notes
+	c.Assert(command.String(), Equals, "aaa_base-15.1\n# Note: not a note of supportconfig\nbash-4.4\n\n")
+	c.Assert(notes, DeepEquals, []supportconfig.SectionNote{
+		{Line: 3, Section: "Command", Header: "# /bin/rpm -qa", Text: "the list is too big, only its first lines are included"},
+		{Line: 4, Section: "Command", Header: "# /bin/rpm -qa", Text: "sorted by name"},
+		{Line: 11, Section: "Configuration File", Header: "# /etc/sample.conf", Text: "the file was changed after installation"},
+	})
+	c.Assert(stats[0].Lines, Equals, int64(6))
+	c.Assert(stats[0].Bytes, Equals, int64(strings.Index(sampleNotes, "#==[ Configuration File")-strings.Index(sampleNotes, "# Note")))
+
+	command = &NopWriteCloser{}
+	p = supportconfig.NewParser()
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		return command, nil
+	})
+	c.Assert(p.Parse(strings.NewReader(sampleNotes)), IsNil)
+	c.Assert(command.String(), Matches, "# Note: the list is too big.*\n(.*\n)+")
+}
+
+func (cs *clientSuite) TestSplitFrom(c *C) {
+	base := c.MkDir()
+	splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: base}}
+	cut := strings.Index(sampleMultipleFiles, "VERSION=")
+	err := splitter.Split(&brokenReader{strings.NewReader(sampleMultipleFiles[:cut])})
+	perr, ok := err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(splitter.Files(), HasLen, 1)
+
+	rest := strings.NewReader(sampleMultipleFiles[perr.Checkpoint.Offset:])
+	c.Assert(splitter.SplitFrom(context.Background(), rest, perr.Checkpoint), IsNil)
+	c.Assert(splitter.Warnings(), HasLen, 0)
+	files := splitter.Files()
+	c.Assert(files, HasLen, 2)
+	c.Assert(files[1].Path, Equals, "/etc/os-release")
+	c.Assert(files[1].Offset, Equals, int64(strings.Index(sampleMultipleFiles, "NAME=")))
+	// the file is written again as a whole
+	b, err := ioutil.ReadFile(filepath.Join(base, "etc/os-release"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, osRelease)
+}
+
+func (cs *clientSuite) TestSplitterNotes(c *C) {
+	config := supportconfig.Config{Base: c.MkDir(), Options: []supportconfig.Option{supportconfig.WithNotes()}}
+	splitter := &supportconfig.Splitter{Config: config}
+	c.Assert(splitter.Split(strings.NewReader(sampleNotes)), IsNil)
+	files := splitter.Files()
+	c.Assert(files, HasLen, 1)
+	c.Assert(files[0].Notes, DeepEquals, []string{"the file was changed after installation"})
+	// the note of supportconfig is left out, the comment of the file kept
+	b, err := ioutil.ReadFile(filepath.Join(config.Base, "etc/sample.conf"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "option = 1\n# Note: a comment of the file\nother = 2\n")
+}
+
+// resumeRecorder keeps the bodies and the ends of the sections parsed,
+// starting a body over when its section is parsed again
+type resumeRecorder struct {
+	bodies map[string]*NopWriteCloser
+	ends   []string
+	opts   []supportconfig.Option
+}
+
+func (r *resumeRecorder) parser() *supportconfig.Parser {
+	p := supportconfig.NewParser(r.opts...)
+	p.HandleDefault(func(name, after string) (io.WriteCloser, error) {
+		key := name + ": " + after
+		r.bodies[key] = &NopWriteCloser{}
+		return r.bodies[key], nil
+	})
+	p.OnSectionEnd(func(section string, lines, bytes int64) {
+		r.ends = append(r.ends, fmt.Sprintf("%s %d %d", section, lines, bytes))
+	})
+	return p
+}
+
+func (cs *clientSuite) TestParseFrom(c *C) {
+	testParseFrom(c, sampleMultipleGroups+logEntryWithNote)
+}
+
+func (cs *clientSuite) TestParseFromMultilineHeaders(c *C) {
+	testParseFrom(c, sampleMultilineHeaders, supportconfig.WithMultilineHeaders())
+}
+
+func testParseFrom(c *C, source string, opts ...supportconfig.Option) {
+	expected := &resumeRecorder{bodies: make(map[string]*NopWriteCloser), opts: opts}
+	c.Assert(expected.parser().Parse(strings.NewReader(source)), IsNil)
+
+	for cut := 0; cut < len(source); cut++ {
+		r := &resumeRecorder{bodies: make(map[string]*NopWriteCloser), opts: opts}
+		err := r.parser().Parse(&brokenReader{strings.NewReader(source[:cut])})
+		perr, ok := err.(*supportconfig.ParseError)
+		c.Assert(ok, Equals, true, Commentf("cut at %d", cut))
+		c.Assert(errors.Is(err, io.ErrUnexpectedEOF), Equals, true)
+
+		checkpoint := perr.Checkpoint
+		c.Assert(checkpoint.Offset <= int64(cut), Equals, true)
+		c.Assert(checkpoint.Offset == 0 || source[checkpoint.Offset-1] == '\n', Equals, true)
+		rest := strings.NewReader(source[checkpoint.Offset:])
+		err = r.parser().ParseFrom(context.Background(), rest, checkpoint)
+		c.Assert(err, IsNil, Commentf("cut at %d", cut))
+		c.Assert(r.ends, DeepEquals, expected.ends, Commentf("cut at %d", cut))
+		c.Assert(len(r.bodies), Equals, len(expected.bodies))
+		for key, body := range expected.bodies {
+			c.Assert(r.bodies[key], NotNil, Commentf("cut at %d", cut))
+			c.Assert(r.bodies[key].String(), Equals, body.String(), Commentf("cut at %d, %s", cut, key))
+		}
+	}
+}
+
+func (cs *clientSuite) TestParseErrorCheckpoint(c *C) {
+	p := supportconfig.NewParser()
+	source := sampleMultipleGroups
+	cut := strings.Index(source, "Linux node")
+	err := p.Parse(&brokenReader{strings.NewReader(source[:cut+5])})
+	perr, ok := err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	// the section is parsed again from its banner
+	banner := strings.LastIndex(source[:cut], "#==[")
+	c.Assert(perr.Checkpoint, DeepEquals, supportconfig.Checkpoint{
+		Offset:   int64(banner),
+		Line:     int64(strings.Count(source[:banner], "\n")),
+		Sections: 1,
+	})
+
+	// and so is the one ended by the banner where the failure happened
+	p = supportconfig.NewParser()
+	p.HandleStats(func(s supportconfig.SectionStats) error {
+		return fmt.Errorf("failed")
+	})
+	err = p.Parse(strings.NewReader(source))
+	perr, ok = err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	banner = strings.Index(source, "#==[")
+	c.Assert(perr.Checkpoint, DeepEquals, supportconfig.Checkpoint{
+		Offset: int64(banner),
+		Line:   int64(strings.Count(source[:banner], "\n")),
+	})
+}
+
+// limitedCollector fails writes beyond a size
+type limitedCollector struct {
+	io.WriteCloser
+	left int
+}
+
+func (l *limitedCollector) Write(b []byte) (int, error) {
+	if len(b) > l.left {
+		return 0, fmt.Errorf("section too big")
+	}
+	l.left -= len(b)
+	return l.WriteCloser.Write(b)
+}
+
+func (cs *clientSuite) TestParseMiddleware(c *C) {
+	var calls []string
+	var banners []string
+	collector := &NopWriteCloser{}
+	p := supportconfig.NewParser()
+	p.Use(func(next supportconfig.HandlerFunc) supportconfig.HandlerFunc {
+		return func(name, after string) (io.WriteCloser, error) {
+			calls = append(calls, "outer "+after)
+			return next(name, strings.Replace(after, "uname", "UNAME", 1))
+		}
+	}, func(next supportconfig.HandlerFunc) supportconfig.HandlerFunc {
+		return func(name, after string) (io.WriteCloser, error) {
+			calls = append(calls, "inner "+after)
+			w, err := next(name, after)
+			if w == nil || err != nil {
+				return w, err
+			}
+			return &limitedCollector{WriteCloser: w, left: 1000}, nil
+		}
+	})
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		if after == "# /bin/UNAME -a" {
+			return collector, nil
+		}
+		return nil, nil
+	})
+	p.HandleSectionFunc("Command", func(section supportconfig.Section) (io.WriteCloser, error) {
+		banners = append(banners, section.Banner)
+		return nil, nil
+	})
+	c.Assert(p.Parse(strings.NewReader(sampleMultipleGroups)), IsNil)
+	c.Assert(calls[:4], DeepEquals, []string{
+		"outer # /bin/date", "inner # /bin/date",
+		"outer # /bin/date", "inner # /bin/date",
+	})
+	c.Assert(collector.String(), Matches, "Linux node .*\n\n")
+	c.Assert(banners[0], Equals, "#==[ Command ]======================================#")
+
+	p = supportconfig.NewParser()
+	p.Use(func(next supportconfig.HandlerFunc) supportconfig.HandlerFunc {
+		return func(name, after string) (io.WriteCloser, error) {
+			w, err := next(name, after)
+			return &limitedCollector{WriteCloser: w, left: 10}, err
+		}
+	})
+	p.HandleDefault(func(name, after string) (io.WriteCloser, error) {
+		return &NopWriteCloser{}, nil
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, ErrorMatches, `line 13 \(offset \d+\) in section "Command": section too big`)
+}
+
+func (cs *clientSuite) TestSplitterMiddleware(c *C) {
+	base := c.MkDir()
+	rename := func(next supportconfig.HandlerFunc) supportconfig.HandlerFunc {
+		return func(name, after string) (io.WriteCloser, error) {
+			return next(name, strings.Replace(after, "os-release", "os-release.orig", 1))
+		}
+	}
+	config := supportconfig.Config{Base: base, Options: []supportconfig.Option{supportconfig.WithMiddleware(rename)}}
+	splitter := &supportconfig.Splitter{Config: config}
+	c.Assert(splitter.Split(strings.NewReader(sampleMultipleFiles)), IsNil)
+	files := splitter.Files()
+	c.Assert(files, HasLen, 2)
+	c.Assert(files[1].Path, Equals, "/etc/os-release.orig")
+	b, err := ioutil.ReadFile(filepath.Join(base, "etc/os-release.orig"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, osRelease)
+}
+
+func (cs *clientSuite) TestParseSmallBuffer(c *C) {
+	collector := &NopWriteCloser{}
+	p := supportconfig.NewParser(supportconfig.WithBufferSize(16))
+	p.HandleSection("Configuration File", func(name, after string) (io.WriteCloser, error) {
+		c.Assert(after, Equals, "# /etc/SuSE-release")
+		return collector, nil
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, IsNil)
+	c.Assert(collector.String(), Equals, etcRelease+UglyExtraNewlines)
+}
+
+func (cs *clientSuite) TestParseMaxLineSize(c *C) {
+	p := supportconfig.NewParser(supportconfig.WithBufferSize(16), supportconfig.WithMaxLineSize(64))
+	err := p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(errors.Is(err, supportconfig.ErrLineTooLong), Equals, true)
+	perr, ok := err.(*supportconfig.ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(perr.Line, Equals, int64(2))
+}
+
+func (cs *clientSuite) TestParseTruncateLongLines(c *C) {
+	var warnings []supportconfig.Warning
+	var size int64
+	collector := &NopWriteCloser{}
+	p := supportconfig.NewParser(
+		supportconfig.WithBufferSize(16),
+		supportconfig.WithMaxLineSize(24),
+		supportconfig.WithTruncateLongLines())
+	p.HandleSection("Command", func(name, after string) (io.WriteCloser, error) {
+		if after == "# /bin/uname -a" {
+			return collector, nil
+		}
+		return nil, nil
+	})
+	p.HandleWarning(func(w supportconfig.Warning) {
+		warnings = append(warnings, w)
+	})
+	p.HandleStats(func(stats supportconfig.SectionStats) error {
+		if stats.Header == "# /bin/uname -a" {
+			size = stats.Bytes
+		}
+		return nil
+	})
+	err := p.Parse(strings.NewReader(sampleMultipleGroups))
+	c.Assert(err, IsNil)
+	c.Assert(collector.String(), Equals, "Linux node 4.4.121-92.85\n\n")
+	c.Assert(size, Equals, int64(111))
+	c.Assert(len(warnings) > 0, Equals, true)
+	c.Assert(warnings[0], DeepEquals, supportconfig.Warning{Line: 2, Message: "line truncated"})
+}
+
+func (cs *clientSuite) TestSplitterOptions(c *C) {
+	config := supportconfig.Config{
+		Base:    c.MkDir(),
+		Options: []supportconfig.Option{supportconfig.WithMaxLineSize(10)},
+	}
+	splitter := &supportconfig.Splitter{Config: config}
+	err := splitter.Split(strings.NewReader(sampleMultipleGroups))
+	c.Assert(errors.Is(err, supportconfig.ErrLineTooLong), Equals, true)
+}
+
+// benchmarkSource has many sections with short lines, as the ones
+// dominating real bundles
+func benchmarkSource() []byte {
+	var buf bytes.Buffer
+	w := supportconfig.NewWriter(&buf)
+	body := strings.Repeat("Apr  7 20:23:42 node kernel: [  0.000000] Linux version 4.4.121\n", 200)
+	for i := 0; i < 200; i++ {
+		w.WriteSection("Configuration File", fmt.Sprintf("/etc/file%d.conf", i), strings.NewReader(body))
+		w.WriteSection("Command", "/bin/date", strings.NewReader("today\n"))
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkParse(b *testing.B) {
+	source := benchmarkSource()
+	p := supportconfig.NewParser()
+	p.HandleDefault(func(name, after string) (io.WriteCloser, error) {
+		return nil, nil
+	})
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := p.Parse(bytes.NewReader(source)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type discardCollector struct{}
+
+func (discardCollector) Write(b []byte) (int, error) { return len(b), nil }
+func (discardCollector) Close() error                { return nil }
+
+func BenchmarkParseCollectors(b *testing.B) {
+	source := benchmarkSource()
+	p := supportconfig.NewParser()
+	p.HandleSectionMatch("*", func(name, after string) (io.WriteCloser, error) {
+		return discardCollector{}, nil
+	})
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := p.Parse(bytes.NewReader(source)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSplit(b *testing.B) {
+	source := benchmarkSource()
+	splitter := &supportconfig.Splitter{Config: supportconfig.Config{Base: b.TempDir()}}
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := splitter.Split(bytes.NewReader(source)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package supportconfig
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Tree has the values of the /proc and /sys files found in a
+// supportconfig, such as the ones in proc.txt, indexed by their paths,
+// so that analyzers can read kernel settings without caring about which
+// section they were dumped in. The few configuration files read by the
+// checks are kept as well.
+type Tree struct {
+	root treeNode
+
+	// only has the paths whose values are read from the source, with
+	// everything below them, or nil to read them all
+	only []string
+}
+
+type treeNode struct {
+	value    string
+	set      bool
+	children map[string]*treeNode
+}
+
+// NewTree creates an empty Tree
+func NewTree() *Tree {
+	return &Tree{}
+}
+
+// newTreeOf creates an empty Tree reading only the values of the given
+// paths from the source, for the analyzers needing a few settings
+func newTreeOf(paths ...string) *Tree {
+	return &Tree{only: paths}
+}
+
+// wants says whether the value of a path is read from the source
+func (t *Tree) wants(name string) bool {
+	if t.only == nil {
+		return true
+	}
+	for _, only := range t.only {
+		if name == only || strings.HasPrefix(name, only+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// treePrefixes are the directories whose files are kept in the tree
+var treePrefixes = []string{"/proc/", "/sys/"}
+
+// treeFiles are the configuration files kept in the tree
+var treeFiles = []string{
+	"/etc/crypto-policies/config",
+	"/etc/login.defs",
+	"/etc/security/pwquality.conf",
+	"/etc/pam.d/common-password",
+	"/etc/shadow",
+}
+
+// treeFilters change the contents of files before they are kept in the
+// tree, so that secrets don't end up in it
+var treeFilters = map[string]func(content string) string{
+	"/etc/shadow": maskShadow,
+}
+
+// maskShadow replaces the password hashes of a shadow file, keeping
+// whether the accounts are locked or have no password
+func maskShadow(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		fields := strings.Split(line, ":")
+		if len(fields) < 2 {
+			continue
+		}
+		switch hash := fields[1]; {
+		case hash == "" || hash == "*" || hash == "!" || hash == "!*":
+		case strings.HasPrefix(hash, "!"):
+			fields[1] = "!x"
+		default:
+			fields[1] = "x"
+		}
+		lines[i] = strings.Join(fields, ":")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// keepInTree says whether a file found in a supportconfig is kept in the
+// tree
+func keepInTree(name string) bool {
+	for _, prefix := range treePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return containsString(treeFiles, name)
+}
+
+func splitTreePath(name string) []string {
+	return strings.Split(strings.Trim(path.Clean(name), "/"), "/")
+}
+
+// Set sets the value for a path, replacing any previous one
+func (t *Tree) Set(name, value string) {
+	node := &t.root
+	for _, part := range splitTreePath(name) {
+		if node.children == nil {
+			node.children = make(map[string]*treeNode)
+		}
+		child, found := node.children[part]
+		if !found {
+			child = &treeNode{}
+			node.children[part] = child
+		}
+		node = child
+	}
+	node.value = value
+	node.set = true
+}
+
+func (t *Tree) lookup(name string) *treeNode {
+	node := &t.root
+	for _, part := range splitTreePath(name) {
+		node = node.children[part]
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// Get returns the value for a path, such as /proc/sys/vm/swappiness
+func (t *Tree) Get(name string) (string, bool) {
+	node := t.lookup(name)
+	if node == nil || !node.set {
+		return "", false
+	}
+	return node.value, true
+}
+
+// Children returns the sorted names of the entries under a path
+func (t *Tree) Children(name string) []string {
+	node := t.lookup(name)
+	if node == nil {
+		return nil
+	}
+	names := make([]string, 0, len(node.children))
+	for child := range node.children {
+		names = append(names, child)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Glob returns the sorted paths with values matching a pattern, with the
+// syntax of path.Match, such as /sys/block/*/queue/scheduler
+func (t *Tree) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var matches []string
+	t.root.walk("", splitTreePath(pattern), &matches)
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func (n *treeNode) walk(prefix string, parts []string, matches *[]string) {
+	if len(parts) == 0 {
+		if n.set {
+			*matches = append(*matches, prefix)
+		}
+		return
+	}
+	for name, child := range n.children {
+		if matched, _ := path.Match(parts[0], name); matched {
+			child.walk(prefix+"/"+name, parts[1:], matches)
+		}
+	}
+}
+
+// ParseTree builds a Tree from the /proc and /sys files, and the
+// configuration files read by the checks, found in the Configuration
+// File sections of the source and from the output of sysctl -a, whose
+// keys are stored under /proc/sys
+func ParseTree(source io.Reader) (*Tree, error) {
+	tree := NewTree()
+	if err := Analyze(source, TreeAnalyzer(tree)); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// TreeAnalyzer works as ParseTree, filling a tree made with NewTree
+func TreeAnalyzer(tree *Tree) Analyzer {
+	return func(p *Parser) func() error {
+		p.HandleSection("Configuration File", tree.fileHandler)
+		p.HandleSection("Command", tree.sysctlHandler)
+		return noDone
+	}
+}
+
+// treeWriter collects the body of a section and passes it to done when
+// closed
+type treeWriter struct {
+	bytes.Buffer
+	done func(body []byte)
+}
+
+func (w *treeWriter) Close() error {
+	w.done(w.Bytes())
+	return nil
+}
+
+// lineWriter passes each line of the body of a section, without the line
+// break, to line
+type lineWriter struct {
+	line    func(line []byte)
+	partial []byte
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.partial = append(w.partial, b...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		w.line(w.partial[:idx])
+		w.partial = w.partial[idx+1:]
+	}
+	return len(b), nil
+}
+
+func (w *lineWriter) Close() error {
+	if len(w.partial) > 0 {
+		w.line(w.partial)
+		w.partial = nil
+	}
+	return nil
+}
+
+func (t *Tree) fileHandler(section, afterline string) (io.WriteCloser, error) {
+	name, err := afterlineToPath(strings.TrimPrefix(afterline, "# "))
+	if err != nil {
+		return nil, err
+	}
+	name = path.Clean(name)
+	if !keepInTree(name) || !t.wants(name) {
+		return nil, nil
+	}
+	return &treeWriter{done: func(body []byte) {
+		content := string(bytes.TrimRight(body, "\n"))
+		if filter, found := treeFilters[name]; found {
+			content = filter(content)
+		}
+		t.Set(name, content)
+	}}, nil
+}
+
+func (t *Tree) sysctlHandler(section, afterline string) (io.WriteCloser, error) {
+	fields := strings.Fields(strings.TrimPrefix(afterline, "# "))
+	if len(fields) < 2 || path.Base(fields[0]) != "sysctl" || fields[1] != "-a" {
+		return nil, nil
+	}
+	return &treeWriter{done: t.setSysctl}, nil
+}
+
+// setSysctl stores lines such as "vm.swappiness = 60"
+func (t *Tree) setSysctl(body []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		idx := strings.Index(scanner.Text(), " = ")
+		if idx < 1 {
+			continue
+		}
+		name := "/proc/sys/" + sysctlPath(scanner.Text()[:idx])
+		if t.wants(name) {
+			t.Set(name, scanner.Text()[idx+3:])
+		}
+	}
+}
+
+// sysctlPath returns the path under /proc/sys of a sysctl key. sysctl
+// writes the dots found in the names of the files, as in interfaces
+// such as eth0.100, as slashes.
+func sysctlPath(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return '/'
+		case '/':
+			return '.'
+		}
+		return r
+	}, key)
+}
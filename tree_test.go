@@ -0,0 +1,108 @@
+package supportconfig_test
+
+import (
+	"strings"
+
+	"github.com/bhdn/go-supportconfig"
+	. "gopkg.in/check.v1"
+)
+
+type treeSuite struct {
+}
+
+var _ = Suite(&treeSuite{})
+
+const sampleProc = `#==[ Configuration File ]===========================#
+# /proc/sys/vm/swappiness
+60
+
+#==[ Configuration File ]===========================#
+# /sys/block/sda/queue/scheduler
+noop deadline [cfq]
+
+#==[ Configuration File ]===========================#
+# /sys/block/sdb/queue/scheduler
+[none] mq-deadline
+
+#==[ Configuration File ]===========================#
+# /etc/sysctl.conf
+vm.swappiness = 10
+
+#==[ Configuration File ]===========================#
+# /proc/missing - File not found
+
+#==[ Configuration File ]===========================#
+# /etc/crypto-policies/config
+FIPS
+
+#==[ Command ]======================================#
+# /sbin/sysctl -a
+kernel.pid_max = 32768
+net.ipv4.ip_forward = 0
+net.ipv6.conf.eth0/100.accept_ra = 1
+
+`
+
+func (ts *treeSuite) TestParseTree(c *C) {
+	tree, err := supportconfig.ParseTree(strings.NewReader(sampleProc))
+	c.Assert(err, IsNil)
+
+	for name, expected := range map[string]string{
+		"/proc/sys/vm/swappiness":                    "60",
+		"/sys/block/sda/queue/scheduler":             "noop deadline [cfq]",
+		"/proc/sys/kernel/pid_max":                   "32768",
+		"/proc/sys/net/ipv4/ip_forward":              "0",
+		"/proc/sys/net/ipv6/conf/eth0.100/accept_ra": "1",
+		"/sys/block/sdb/queue/scheduler/":            "[none] mq-deadline",
+		"/etc/crypto-policies/config":                "FIPS",
+	} {
+		value, found := tree.Get(name)
+		c.Assert(found, Equals, true, Commentf("path %s", name))
+		c.Assert(value, Equals, expected, Commentf("path %s", name))
+	}
+	for _, name := range []string{"/etc/sysctl.conf", "/proc/missing", "/sys/block"} {
+		_, found := tree.Get(name)
+		c.Assert(found, Equals, false, Commentf("path %s", name))
+	}
+	c.Assert(tree.Children("/sys/block"), DeepEquals, []string{"sda", "sdb"})
+}
+
+func (ts *treeSuite) TestTreeGlob(c *C) {
+	tree, err := supportconfig.ParseTree(strings.NewReader(sampleProc))
+	c.Assert(err, IsNil)
+	matches, err := tree.Glob("/sys/block/*/queue/scheduler")
+	c.Assert(err, IsNil)
+	c.Assert(matches, DeepEquals, []string{"/sys/block/sda/queue/scheduler", "/sys/block/sdb/queue/scheduler"})
+
+	_, err = tree.Glob("/sys/[")
+	c.Assert(err, NotNil)
+}
+
+func (ts *treeSuite) TestTreeOf(c *C) {
+	source := sampleProc + `#==[ Configuration File ]===========================#
+# /proc/sys/net/ipv6/conf/eth0/disable_ipv6
+0
+
+#==[ Command ]======================================#
+# /sbin/sysctl -a
+net.ipv6.conf.eth0.accept_ra = 2
+
+`
+	tree := supportconfig.NewTreeOf("/proc/sys/net/ipv6/conf")
+	c.Assert(supportconfig.Analyze(strings.NewReader(source), supportconfig.TreeAnalyzer(tree)), IsNil)
+	for name, expected := range map[string]string{
+		"/proc/sys/net/ipv6/conf/eth0/disable_ipv6":  "0",
+		"/proc/sys/net/ipv6/conf/eth0/accept_ra":     "2",
+		"/proc/sys/net/ipv6/conf/eth0.100/accept_ra": "1",
+	} {
+		value, found := tree.Get(name)
+		c.Assert(found, Equals, true, Commentf("path %s", name))
+		c.Assert(value, Equals, expected, Commentf("path %s", name))
+	}
+	// nothing outside of the wanted paths is kept
+	c.Assert(tree.Children("/sys"), HasLen, 0)
+	c.Assert(tree.Children("/proc/sys"), DeepEquals, []string{"net"})
+	c.Assert(tree.Children("/proc/sys/net"), DeepEquals, []string{"ipv6"})
+	_, found := tree.Get("/etc/crypto-policies/config")
+	c.Assert(found, Equals, false)
+}